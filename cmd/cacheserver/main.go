@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path"
+
+	"github.com/super-yaoj/yaoj-core/internal/app/cacheserver"
+	"github.com/super-yaoj/yaoj-core/pkg/log"
+)
+
+var address string
+var dir string
+var token string
+
+func main() {
+	flag.Parse()
+
+	lg := log.NewTerminal()
+	if token == "" {
+		lg.Warn("no token set, anyone who can reach the server can forge judging results")
+	}
+	server, err := cacheserver.New(dir, token, lg)
+	if err != nil {
+		lg.Fatal(err)
+	}
+
+	err = server.Run(address)
+	if err != nil {
+		lg.Fatal(err)
+	}
+}
+
+func init() {
+	flag.StringVar(&address, "listen", "localhost:3100", "listening address")
+	flag.StringVar(&dir, "dir", path.Join(os.TempDir(), "yaoj-cacheserver"), "data directory")
+	flag.StringVar(&token, "token", os.Getenv("YAOJ_CACHE_TOKEN"), "token shared with judges (default $YAOJ_CACHE_TOKEN)")
+}
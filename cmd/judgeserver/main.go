@@ -4,20 +4,60 @@ import (
 	"flag"
 	"os"
 	"path"
+	"time"
 
 	"github.com/super-yaoj/yaoj-core/internal/app/judgeserver"
+	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
+	"github.com/super-yaoj/yaoj-core/internal/pkg/processors"
+	"github.com/super-yaoj/yaoj-core/internal/pkg/worker"
+	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
 	"github.com/super-yaoj/yaoj-core/pkg/log"
 )
 
 var address string
+var cacheBudget int64
+var remoteCache string
+var remoteCacheTimeout time.Duration
+var remoteCacheToken string
+var processorDir string
+var judgerBackend string
+var allowUnisolated bool
 
 func main() {
 	flag.Parse()
 
 	lg := log.NewTerminal()
+	if judgerBackend == "" && os.Geteuid() == 0 {
+		// 有权限时默认使用沙箱
+		if backend, err := judger.ParseBackend("sandbox"); err == nil {
+			judger.SetBackend(backend)
+		}
+	} else if judgerBackend != "" {
+		backend, err := judger.ParseBackend(judgerBackend)
+		if err != nil {
+			lg.Fatal(err)
+		}
+		judger.SetBackend(backend)
+	}
+	if backend := judger.CurrentBackend(); !judger.Isolates(backend) {
+		if !allowUnisolated {
+			lg.Fatalf("judger backend %T does not isolate submissions, use -judger sandbox (as root) or -allow-unisolated", backend)
+		}
+		lg.Warnf("judger backend %T does not isolate submissions", backend)
+	}
+	if processorDir != "" {
+		if err := processors.LoadDir(processorDir); err != nil {
+			lg.Fatal(err)
+		}
+	}
 	server := judgeserver.New(lg)
 	dir := path.Join(os.TempDir(), "yaoj-judgeserver")
-	err := judgeserver.Init(dir, lg)
+	err := judgeserver.Init(dir, lg,
+		worker.WithCacheBudget(cacheBudget*1024*1024),
+		worker.WithRemoteCache(remoteCache),
+		worker.WithRemoteCacheTimeout(remoteCacheTimeout),
+		worker.WithRemoteCacheToken(remoteCacheToken),
+	)
 	if err != nil {
 		lg.Fatal(err)
 	}
@@ -30,4 +70,11 @@ func main() {
 
 func init() {
 	flag.StringVar(&address, "listen", "localhost:3000", "listening address")
+	flag.Int64Var(&cacheBudget, "cache", 0, "global cache budget (MB), 0 for unlimited")
+	flag.StringVar(&remoteCache, "remote-cache", "", "address of remote cache server, e.g. http://localhost:3100")
+	flag.StringVar(&remoteCacheToken, "remote-cache-token", os.Getenv("YAOJ_CACHE_TOKEN"), "token shared with the remote cache server (default $YAOJ_CACHE_TOKEN)")
+	flag.DurationVar(&remoteCacheTimeout, "remote-cache-timeout", workflowruntime.DefaultRemoteTimeout, "timeout of each request to the remote cache server")
+	flag.StringVar(&judgerBackend, "judger", "", "judger backend: cgo, exec or sandbox[:<cgroup dir>], default sandbox when run as root")
+	flag.BoolVar(&allowUnisolated, "allow-unisolated", false, "allow a judger backend that does not isolate submissions (unsafe)")
+	flag.StringVar(&processorDir, "processors", "", "directory of external processor definitions (*.json)")
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+)
+
+func init() {
+	commands["diff"] = command{
+		usage: "compare the workflows of two problem archives (or workflow json)",
+		run:   diff,
+	}
+}
+
+func diff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the difference as json")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: probtool diff [-json] <old> <new>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	a, err := loadWorkflow(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := loadWorkflow(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	res := workflow.Diff(a, b)
+	if *asJSON {
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if res.Empty() {
+		fmt.Println("workflow unchanged")
+		return nil
+	}
+	fmt.Println(res)
+	return nil
+}
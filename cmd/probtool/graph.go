@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/super-yaoj/yaoj-core/pkg/problem"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+)
+
+func init() {
+	commands["graph"] = command{
+		usage: "render the workflow of a problem archive (or workflow json) as DOT or Mermaid",
+		run:   graph,
+	}
+}
+
+// 加载题目压缩包或者 workflow 的 json 文件中的 workflow
+func loadWorkflow(name string) (*workflow.Workflow, error) {
+	if strings.HasSuffix(name, ".json") {
+		return workflow.LoadFile(name)
+	}
+	dir, err := os.MkdirTemp("", "probtool-")
+	if err != nil {
+		return nil, err
+	}
+	prob, err := problem.LoadZip(name, dir, problem.DefaultExtractLimits)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	defer prob.Finalize()
+	if prob.Workflow == nil {
+		return nil, fmt.Errorf("%s: workflow not set", name)
+	}
+	return prob.Workflow, nil
+}
+
+func graph(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := flags.String("format", "dot", "output format: dot or mermaid")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: probtool graph [-format dot|mermaid] <problem.zip|workflow.json>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	wk, err := loadWorkflow(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "dot":
+		fmt.Print(wk.DOT())
+	case "mermaid":
+		fmt.Print(wk.Mermaid())
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	return nil
+}
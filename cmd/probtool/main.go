@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/super-yaoj/yaoj-core/pkg/log"
+)
+
+var lg = log.NewTerminal()
+
+// 子命令
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [arguments]\n\ncommands:\n", os.Args[0])
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].usage)
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(flag.Args()[1:]); err != nil {
+		lg.WithError(err).Error(flag.Arg(0))
+		os.Exit(1)
+	}
+}
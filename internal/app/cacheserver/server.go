@@ -0,0 +1,166 @@
+// Package cacheserver 是远程结点缓存（见 workflowruntime.RemoteCache）的参考实现
+//
+// 所有数据直接存放在文件系统中，不做淘汰。
+//
+// 服务端只检查 blob 的校验值与记录引用的 blob 是否存在，不检查记录中的执行结果，
+// 而评测端会直接信任这些结果。因此服务只能对可信的评测端开放，并且应当设置
+// token：所有请求都需要带上 "Authorization: Bearer <token>"。
+package cacheserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/log"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+)
+
+// 单个请求体的大小上限
+const MaxBodySize = 256 << 20
+
+var sumPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+type Server struct {
+	*gin.Engine
+	// 数据的存放位置，blob 和 node 分别存放在对应的子目录中
+	dir string
+	// 共享的 token，为空表示不验证
+	token string
+	lg    *log.Entry
+}
+
+// 验证请求的 token
+func (r *Server) auth(ctx *gin.Context) {
+	if r.token == "" {
+		return
+	}
+	got := []byte(ctx.GetHeader("Authorization"))
+	if subtle.ConstantTimeCompare(got, []byte("Bearer "+r.token)) != 1 {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	}
+}
+
+func (r *Server) file(kind, key string) string {
+	return path.Join(r.dir, kind, key)
+}
+
+func (r *Server) exist(kind, key string) bool {
+	_, err := os.Stat(r.file(kind, key))
+	return err == nil
+}
+
+// 先写入临时文件再重命名，避免读到不完整的文件
+func (r *Server) write(kind, key string, ctnt []byte) error {
+	tmp := path.Join(r.dir, ".tmp-"+utils.RandomString(10))
+	if err := os.WriteFile(tmp, ctnt, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.file(kind, key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (r *Server) handle(kind string, validate func(key string, ctnt []byte) error) {
+	route := "/" + kind + "/:key"
+	key := func(ctx *gin.Context) (string, bool) {
+		key := ctx.Param("key")
+		if !sumPattern.MatchString(key) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid key"})
+			return "", false
+		}
+		return key, true
+	}
+	r.HEAD(route, func(ctx *gin.Context) {
+		if key, ok := key(ctx); ok {
+			if r.exist(kind, key) {
+				ctx.Status(http.StatusOK)
+			} else {
+				ctx.Status(http.StatusNotFound)
+			}
+		}
+	})
+	r.GET(route, func(ctx *gin.Context) {
+		if key, ok := key(ctx); ok {
+			if r.exist(kind, key) {
+				ctx.File(r.file(kind, key))
+			} else {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			}
+		}
+	})
+	r.PUT(route, func(ctx *gin.Context) {
+		key, ok := key(ctx)
+		if !ok {
+			return
+		}
+		ctnt, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, MaxBodySize))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate(key, ctnt); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := r.write(kind, key, ctnt); err != nil {
+			r.lg.WithError(err).Error("write " + kind)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+}
+
+// blob 的 sum 必须与内容一致
+func (r *Server) validateBlob(key string, ctnt []byte) error {
+	if sum := fmt.Sprintf("%x", sha256.Sum256(ctnt)); sum != key {
+		return fmt.Errorf("checksum mismatch: %s", sum)
+	}
+	return nil
+}
+
+// node 引用的 blob 必须都已经存在
+func (r *Server) validateNode(key string, ctnt []byte) error {
+	var manifest workflowruntime.RemoteManifest
+	if err := json.Unmarshal(ctnt, &manifest); err != nil {
+		return err
+	}
+	for label, sum := range manifest.Output {
+		if !sumPattern.MatchString(sum) || !r.exist("blob", sum) {
+			return fmt.Errorf("missing blob of %q: %s", label, sum)
+		}
+	}
+	return nil
+}
+
+// create dir if necessary
+//
+// token 为评测端共享的 token，为空表示不验证（仅用于测试或者完全隔离的网络）
+func New(dir string, token string, logger *log.Entry) (*Server, error) {
+	for _, kind := range []string{"blob", "node"} {
+		if err := os.MkdirAll(path.Join(dir, kind), 0750); err != nil {
+			return nil, err
+		}
+	}
+	server := &Server{
+		Engine: gin.New(),
+		dir:    dir,
+		token:  token,
+		lg:     logger,
+	}
+	server.Use(gin.Recovery(), server.auth)
+	server.handle("blob", server.validateBlob)
+	server.handle("node", server.validateNode)
+	return server, nil
+}
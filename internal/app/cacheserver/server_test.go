@@ -0,0 +1,169 @@
+package cacheserver_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/super-yaoj/yaoj-core/internal/app/cacheserver"
+	"github.com/super-yaoj/yaoj-core/internal/pkg/analyzers"
+	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
+	"github.com/super-yaoj/yaoj-core/internal/tests"
+	"github.com/super-yaoj/yaoj-core/pkg/data"
+	"github.com/super-yaoj/yaoj-core/pkg/log"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow/preset"
+	yutils "github.com/super-yaoj/yaoj-utils"
+)
+
+func TestServer(t *testing.T) {
+	lg := log.NewTest()
+	server, err := cacheserver.New(t.TempDir(), "secret", lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	t.Run("BadRequest", func(t *testing.T) {
+		for url, body := range map[string]string{
+			// checksum mismatch
+			"/blob/" + strings.Repeat("0", 64): "data",
+			// invalid key
+			"/blob/..": "data",
+			// missing blob
+			"/node/" + strings.Repeat("0", 64): `{"output":{"result":"` + strings.Repeat("0", 64) + `"}}`,
+		} {
+			req, err := http.NewRequest(http.MethodPut, ts.URL+url, bytes.NewReader([]byte(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer secret")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				t.Fatal("invalid request accepted", url)
+			}
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		blob := "data"
+		sum := fmt.Sprintf("%x", sha256.Sum256([]byte(blob)))
+		for _, token := range []string{"", "Bearer wrong"} {
+			req, err := http.NewRequest(http.MethodPut, ts.URL+"/blob/"+sum, strings.NewReader(blob))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != "" {
+				req.Header.Set("Authorization", token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatal("request without valid token accepted", token, resp.Status)
+			}
+		}
+	})
+
+	t.Run("RemoteCache", func(t *testing.T) {
+		dir := t.TempDir()
+		inbounds := workflow.InboundGroups{
+			workflow.Gstatic: {
+				"checker": data.NewFile(path.Join(dir, "_chk.cpp"), []byte(tests.NcmpSource)),
+				"runner_config": data.NewFile(path.Join(dir, "_runconf"), (&data.RunConf{
+					RealTime: 60 * 1000,
+					CpuTime:  1000,
+					RealMem:  512 * 1024 * 1024,
+					StkMem:   512 * 1024 * 1024,
+					Output:   64 * 1024 * 1024,
+					Fileno:   5,
+				}).Serialize()),
+			},
+			workflow.Gtests: {
+				"input":  data.NewFile(path.Join(dir, "_input"), []byte("1 2")),
+				"output": data.NewFile(path.Join(dir, "_output"), []byte("3")),
+			},
+			workflow.Gsubm: {
+				"source": data.NewFile(path.Join(dir, "_main.cpp"), []byte(tests.APlusBSourceCpp)),
+				"option": data.NewFile(path.Join(dir, "_cpl"), (&data.CompileConf{
+					Lang: yutils.Lcpp11,
+				}).Serialize()),
+			},
+		}
+		remote := workflowruntime.NewRemoteCache(ts.URL, "secret", 0, lg)
+
+		// run with local and remote cache
+		run := func() (*workflowruntime.RtWorkflow, *workflowruntime.GlobalCache) {
+			local, err := workflowruntime.NewCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			wk, err := workflowruntime.New(&preset.Traditional, t.TempDir(), 100, analyzers.Traditional{}, lg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wk.UseCache(local, remote)
+			res, err := wk.Run(inbounds, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Title != "Accepted" {
+				t.Fatal("invalid result", res)
+			}
+			return wk, local
+		}
+
+		wk, _ := run()
+		for name, node := range wk.RtNodes {
+			if node.Cache && !remote.Exist(node) {
+				t.Fatal("remote cache missing", name)
+			}
+		}
+		// remote hits are filled back to the local cache
+		wk, local := run()
+		for name, node := range wk.RtNodes {
+			if node.Cache && !local.Exist(node) {
+				t.Fatal("local cache not filled", name)
+			}
+		}
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		// 一直不响应的服务
+		hung := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-hung
+		}))
+		defer ts.Close()
+		defer close(hung)
+
+		remote := workflowruntime.NewRemoteCache(ts.URL, "", 100*time.Millisecond, lg)
+		wk, err := workflowruntime.New(&preset.Traditional, t.TempDir(), 100, analyzers.Traditional{}, lg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := wk.RtNodes["compile"]
+		done := make(chan bool)
+		go func() { done <- remote.Exist(node) }()
+		select {
+		case exist := <-done:
+			if exist {
+				t.Fatal("hung remote cache reported hit")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("remote cache request not timed out")
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/super-yaoj/yaoj-core/internal/pkg/worker"
 	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
@@ -46,6 +47,52 @@ func Judge(ctx *Context) error {
 	return nil
 }
 
+// 请求体为 multipart 表单，包含 std, target, hack 三个文件，均为提交记录的格式
+func Hack(ctx *Context) error {
+	type Hack struct {
+		Callback string `form:"cb" binding:"required"`
+		Checksum string `form:"sum" binding:"required"`
+	}
+	var qry Hack
+	if err := ctx.BindQuery(&qry); err != nil {
+		return &HttpError{http.StatusBadRequest, yerrors.Situated("bind query", err)}
+	}
+
+	var files = map[string][]byte{}
+	for _, name := range []string{"std", "target", "hack"} {
+		header, err := ctx.FormFile(name)
+		if err != nil {
+			return &HttpError{http.StatusBadRequest, yerrors.Situated("form file "+name, err)}
+		}
+		file, err := header.Open()
+		if err != nil {
+			return err
+		}
+		files[name], err = io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	// ready to judge
+	ctx.JSON(http.StatusOK, gin.H{"message": "ok"})
+
+	go func() {
+		result, err := workerService.RunHack(qry.Checksum, files["std"], files["target"], files["hack"])
+		if err != nil {
+			ctx.lg.Errorf("run hack: %v", err)
+			return
+		}
+
+		_, err = http.Post(qry.Callback, "text/json; charset=utf-8", bytes.NewReader(result.JSON()))
+		if err != nil {
+			ctx.lg.Errorf("callback request: %v", err)
+		}
+	}()
+
+	return nil
+}
+
 func CustomTest(ctx *Context) error {
 	type CustomTest struct {
 		Callback string `form:"cb" binding:"required"`
@@ -87,7 +134,9 @@ func Sync(ctx *Context) error {
 	}
 
 	err := workerService.SetProblem(qry.Checksum, ctx.Request.Body)
-	if err != nil {
+	if yerrors.Is(err, worker.ErrInvalidProblem) || yerrors.Is(err, worker.ErrInvalidChecksum) {
+		return &HttpError{http.StatusBadRequest, err}
+	} else if err != nil {
 		return err
 	}
 
@@ -44,6 +44,7 @@ func New(logger *log.Entry) *Server {
 	server.Use(gin.Recovery())
 
 	server.Handle("/judge", "POST", Judge)
+	server.Handle("/hack", "POST", Hack)
 	server.Handle("/custom", "POST", CustomTest)
 	server.Handle("/sync", "POST", Sync)
 
@@ -70,8 +71,8 @@ func New(logger *log.Entry) *Server {
 
 var workerService *worker.Service
 
-func Init(dir string, logger *log.Entry) error {
-	service, err := worker.New(dir, logger)
+func Init(dir string, logger *log.Entry, options ...worker.OptionProvider) error {
+	service, err := worker.New(dir, logger, options...)
 	if err != nil {
 		return err
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -120,6 +121,62 @@ func TestServer(t *testing.T) {
 		}
 	})
 
+	t.Run("Hack", func(t *testing.T) {
+		finish := make(chan int)
+		// add handler
+		cbserver.HandleFunc("/hack_cb", func(w http.ResponseWriter, r *http.Request) {
+			resdata, _ := io.ReadAll(r.Body)
+			lg.Debug(string(resdata))
+			result := problem.HackResult{}
+			err := json.Unmarshal(resdata, &result)
+			if err != nil {
+				lg.Error(err)
+				finish <- 1
+				return
+			}
+			if !result.Success {
+				lg.Error("hack failed")
+				finish <- 1
+				return
+			}
+			finish <- 0
+		})
+
+		var buf bytes.Buffer
+		form := multipart.NewWriter(&buf)
+		for name, subm := range map[string]problem.Submission{
+			"std":    tests.CreateSubmission(),
+			"target": tests.CreateWrongSubmission(),
+			"hack":   tests.CreateHack("1 2"),
+		} {
+			file, err := form.CreateFormFile(name, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			subm.DumpTo(file)
+		}
+		form.Close()
+
+		req, err := http.NewRequest("POST", "/hack?sum="+Checksum+"&cb="+url.QueryEscape("http://"+cbaddr+"/hack_cb"), &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec := httptest.NewRecorder()
+
+		server.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			data, _ := io.ReadAll(rec.Result().Body)
+			lg.Error(string(data))
+			t.Fatal(string(data))
+		}
+		// wait judgement finish
+		rescode := <-finish
+		if rescode != 0 {
+			t.Fatal("res code not zero")
+		}
+	})
+
 	t.Run("Judge(BadRequest)", func(t *testing.T) {
 		// bind error
 		req, err := http.NewRequest("POST", "/judge?sum="+Checksum, nil)
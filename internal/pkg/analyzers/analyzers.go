@@ -1,6 +1,8 @@
 package analyzers
 
 import (
+	"io"
+
 	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow"
@@ -9,11 +11,18 @@ import (
 type Analyzer = workflowruntime.Analyzer
 
 // Try to display content of a text file with max-length limitation.
+//
+// store 可以为 nil（例如结点被跳过）
 func show(store data.FileStore, title string, length int) workflow.ResultFile {
-	bytes, _ := store.Get()
-	content := string(bytes)
-	if len(content) > length {
-		content = content[:length]
+	// 只读取需要展示的部分
+	var content string
+	if store == nil {
+		return workflow.ResultFile{Title: title}
+	}
+	if src, err := store.Open(); err == nil {
+		bytes, _ := io.ReadAll(io.LimitReader(src, int64(length)))
+		src.Close()
+		content = string(bytes)
 	}
 	return workflow.ResultFile{
 		Title:   title,
@@ -0,0 +1,180 @@
+package analyzers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
+	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+)
+
+// 外部分析器的可执行文件所在的 Gstatic 字段
+const ExternalField = "analyzer"
+
+// 外部分析器的运行时间限制（实际时间与 CPU 时间）
+const ExternalTimeout = 10 * time.Second
+
+// 外部分析器的内存限制
+const ExternalMemory = 512 * judger.MB
+
+// 外部分析器可以同时打开的文件数
+const ExternalFileno = 64
+
+// 传给外部分析器的结点信息
+type ExternalNode struct {
+	// processor name
+	Processor string `json:"processor"`
+	// 执行结果，未执行时为 nil
+	Result *processor.Result `json:"result"`
+	// 是否因为执行条件不满足而被跳过
+	Skipped bool `json:"skipped"`
+	// 输入文件的绝对路径
+	Input map[string]string `json:"input"`
+	// 输出文件的绝对路径
+	Output map[string]string `json:"output"`
+}
+
+// 传给外部分析器的 workflow 信息（以 JSON 的形式写入其标准输入）
+type ExternalInput struct {
+	Fullscore float64                 `json:"fullscore"`
+	Nodes     map[string]ExternalNode `json:"nodes"`
+}
+
+// 由题目提供的可执行文件完成分析，使得题目可以完全自定义计分方式
+//
+// 可执行文件取自 Gstatic 的 [ExternalField] 字段，以 "builtin:yaoj" 策略在一个
+// 临时的私有目录中运行（见 [judger.WithIsolation]），除了工具链之外只能读取
+// [ExternalInput] 中列出的文件，并且有时间、内存与文件数的限制。其标准输入为
+// [ExternalInput] 的 JSON，标准输出应当为 workflow.Result 的 JSON，其中满分总是
+// 题目的满分，得分会被限制在 [0, 满分] 之间。运行结束后所有临时文件都会被删除。
+type External struct {
+}
+
+func (r External) Analyze(w *workflowruntime.RtWorkflow) workflow.Result {
+	exe := w.Inbounds[workflow.Gstatic][ExternalField]
+	if exe == nil {
+		return r.fail(w, "missing analyzer executable")
+	}
+	// 标准输入输出等文件放在私有目录之外，避免被分析器替换
+	dir, err := os.MkdirTemp(".", "analyzer-")
+	if err != nil {
+		return r.fail(w, err.Error())
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.Abs(dir); err != nil {
+		return r.fail(w, err.Error())
+	}
+	box := filepath.Join(dir, "box")
+	if err := os.Mkdir(box, 0755); err != nil {
+		return r.fail(w, err.Error())
+	}
+	// 复制一份，避免修改题目数据的权限
+	exename := filepath.Join(box, "analyzer")
+	if err := exe.DupFile(exename, 0755); err != nil {
+		return r.fail(w, err.Error())
+	}
+
+	input := ExternalInput{
+		Fullscore: w.Fullscore,
+		Nodes:     map[string]ExternalNode{},
+	}
+	readonly := append([]string{}, judger.DefaultReadOnly...)
+	listed := map[string]bool{}
+	for name, node := range w.RtNodes {
+		extnode := ExternalNode{
+			Processor: node.ProcName,
+			Result:    node.Result,
+			Skipped:   node.Skipped,
+			Input:     absPaths(processor.Bounds(node.Input)),
+			Output:    absPaths(processor.Bounds(node.Output)),
+		}
+		for _, paths := range []map[string]string{extnode.Input, extnode.Output} {
+			for _, name := range paths {
+				if !listed[name] {
+					listed[name] = true
+					readonly = append(readonly, name)
+				}
+			}
+		}
+		input.Nodes[name] = extnode
+	}
+	ctnt, err := json.Marshal(input)
+	if err != nil {
+		return r.fail(w, err.Error())
+	}
+	inf, ouf, erf := filepath.Join(dir, "input.json"), filepath.Join(dir, "output.json"), filepath.Join(dir, "stderr")
+	if err := os.WriteFile(inf, ctnt, 0644); err != nil {
+		return r.fail(w, err.Error())
+	}
+
+	res, err := judger.Judge(
+		judger.WithArgument(inf, ouf, erf, exename),
+		judger.WithJudger(judger.General),
+		judger.WithPolicy("builtin:yaoj"),
+		judger.WithLog(filepath.Join(dir, "judger.log"), 0),
+		judger.WithIsolation(box, readonly...),
+		judger.WithRealTime(ExternalTimeout),
+		judger.WithCpuTime(ExternalTimeout),
+		judger.WithRealMemory(ExternalMemory),
+		judger.WithOutput(10*judger.MB),
+		judger.WithFileno(ExternalFileno),
+	)
+	if err != nil {
+		return r.fail(w, err.Error())
+	}
+	if res.Code != processor.Ok {
+		stderr, _ := os.ReadFile(erf)
+		return r.fail(w, res.Msg+"\n"+string(stderr))
+	}
+
+	output, err := os.ReadFile(ouf)
+	if err != nil {
+		return r.fail(w, err.Error())
+	}
+	var result workflow.Result
+	if err := json.Unmarshal(output, &result); err != nil {
+		return r.fail(w, "invalid output: "+err.Error())
+	}
+	// 满分由题目决定，得分不能超出范围
+	result.Fullscore = w.Fullscore
+	if result.Score < 0 {
+		result.Score = 0
+	} else if result.Score > w.Fullscore {
+		result.Score = w.Fullscore
+	}
+	return result
+}
+
+func (r External) fail(w *workflowruntime.RtWorkflow, msg string) workflow.Result {
+	return workflow.Result{
+		ResultMeta: workflow.ResultMeta{
+			Title:     "Analyzer Error",
+			Score:     0,
+			Fullscore: w.Fullscore,
+		},
+		File: []workflow.ResultFile{
+			{Title: "analyzer message", Content: msg},
+		},
+	}
+}
+
+func absPaths(bounds processor.Bounds) map[string]string {
+	res := map[string]string{}
+	for label, store := range bounds {
+		if store == nil {
+			continue
+		}
+		name, err := filepath.Abs(store.Path())
+		if err != nil {
+			continue
+		}
+		res[label] = name
+	}
+	return res
+}
+
+var _ workflowruntime.Analyzer = External{}
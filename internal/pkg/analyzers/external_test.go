@@ -0,0 +1,127 @@
+package analyzers_test
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/super-yaoj/yaoj-core/internal/pkg/analyzers"
+	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
+	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
+	"github.com/super-yaoj/yaoj-core/internal/tests"
+	"github.com/super-yaoj/yaoj-core/pkg/data"
+	"github.com/super-yaoj/yaoj-core/pkg/log"
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow/preset"
+	yutils "github.com/super-yaoj/yaoj-utils"
+)
+
+func TestExternal(t *testing.T) {
+	old := judger.SetBackend(judger.Exec{})
+	defer judger.SetBackend(old)
+
+	dir, wkdir := t.TempDir(), t.TempDir()
+	stdin := path.Join(dir, "stdin.json")
+	inbounds := workflow.InboundGroups{
+		workflow.Gstatic: make(map[string]data.FileStore),
+		workflow.Gsubm:   make(map[string]data.FileStore),
+	}
+	inbounds[workflow.Gsubm]["source"] = data.NewFile(path.Join(dir, "_main.cpp"), []byte(tests.APlusBSourceCpp))
+	inbounds[workflow.Gsubm]["option"] = data.NewFile(path.Join(dir, "_cpl"), (&data.CompileConf{
+		Lang: yutils.Lcpp11,
+	}).Serialize())
+	inbounds[workflow.Gsubm]["input"] = data.NewFile(path.Join(dir, "_input"), []byte("114 514"))
+	inbounds[workflow.Gstatic]["runner_config"] = data.NewFile(path.Join(dir, "_runconf"), (&data.RunConf{
+		RealTime: 60 * 1000,
+		CpuTime:  1000,
+		RealMem:  512 * 1024 * 1024,
+		StkMem:   512 * 1024 * 1024,
+		Output:   64 * 1024 * 1024,
+		Fileno:   5,
+	}).Serialize())
+	// the exec backend does not isolate the analyzer, so it can save its input
+	inbounds[workflow.Gstatic][analyzers.ExternalField] = data.NewFile(path.Join(dir, "_analyzer"),
+		[]byte("#!/bin/sh\ncat > "+stdin+"\necho '{\"Title\": \"Accepted\", \"Score\": 60, \"Fullscore\": 60}'\n"))
+
+	wk, err := workflowruntime.New(&preset.Customtest, wkdir, 100, analyzers.External{}, log.NewTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := wk.Run(inbounds, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Title != "Accepted" || res.Score != 60 || res.Fullscore != 100 {
+		t.Fatal("invalid result", res)
+	}
+
+	t.Run("Input", func(t *testing.T) {
+		ctnt, err := os.ReadFile(stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var input analyzers.ExternalInput
+		if err := json.Unmarshal(ctnt, &input); err != nil {
+			t.Fatal(err)
+		}
+		run := input.Nodes["run"]
+		if input.Fullscore != 100 || len(input.Nodes) != 2 || run.Processor != "runner:auto" ||
+			run.Skipped || run.Result == nil || run.Result.Code != processor.Ok {
+			t.Fatalf("invalid input %s", ctnt)
+		}
+		if !path.IsAbs(run.Output["stdout"]) {
+			t.Fatal("expect absolute path", run.Output)
+		}
+		if output, _ := os.ReadFile(run.Output["stdout"]); strings.TrimSpace(string(output)) != "628" {
+			t.Fatalf("invalid stdout %q", output)
+		}
+	})
+
+	// the analyzer runs in the working directory of the workflow
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(wkdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	analyze := func(t *testing.T, script string) workflow.Result {
+		if script == "" {
+			delete(wk.Inbounds[workflow.Gstatic], analyzers.ExternalField)
+		} else {
+			wk.Inbounds[workflow.Gstatic][analyzers.ExternalField] = data.NewFile(path.Join(t.TempDir(), "_analyzer"),
+				[]byte("#!/bin/sh\n"+script+"\n"))
+		}
+		return analyzers.External{}.Analyze(wk)
+	}
+	expectError := func(t *testing.T, res workflow.Result, msg string) {
+		if res.Title != "Analyzer Error" || res.Score != 0 || res.Fullscore != 100 ||
+			len(res.File) != 1 || !strings.Contains(res.File[0].Content, msg) {
+			t.Fatal("expect analyzer error", res)
+		}
+	}
+
+	t.Run("Clamp", func(t *testing.T) {
+		res := analyze(t, `echo '{"Title": "Accepted", "Score": 1000, "Fullscore": 7}'`)
+		if res.Title != "Accepted" || res.Score != 100 || res.Fullscore != 100 {
+			t.Fatal("invalid result", res)
+		}
+		res = analyze(t, `echo '{"Title": "Wrong Answer", "Score": -5}'`)
+		if res.Title != "Wrong Answer" || res.Score != 0 || res.Fullscore != 100 {
+			t.Fatal("invalid result", res)
+		}
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		expectError(t, analyze(t, "echo not json"), "invalid output")
+	})
+	t.Run("ExitError", func(t *testing.T) {
+		expectError(t, analyze(t, "echo broken >&2; exit 3"), "broken")
+	})
+	t.Run("Missing", func(t *testing.T) {
+		expectError(t, analyze(t, ""), "missing analyzer executable")
+	})
+}
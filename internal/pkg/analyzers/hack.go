@@ -3,6 +3,7 @@ package analyzers
 import (
 	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
 	"github.com/super-yaoj/yaoj-core/pkg/data"
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow"
 )
 
@@ -10,14 +11,40 @@ import (
 type Hack struct {
 	iomap map[string]workflow.Outbound
 	data  map[string]data.FileStore
+	// 没有正常执行的结点的执行结果
+	failed map[string]*processor.Result
 }
 
 func (r *Hack) Analyze(w *workflowruntime.RtWorkflow) workflow.Result {
 	r.data = make(map[string]data.FileStore)
+	r.failed = make(map[string]*processor.Result)
 	for field, bound := range r.iomap {
-		r.data[field] = w.RtNodes[bound.Name].Output[bound.Label]
+		r.data[field] = w.Output(bound)
+		for _, name := range w.Upstream(bound) {
+			if res := w.RtNodes[name].Result; res == nil || res.Code != processor.Ok {
+				r.failed[name] = res
+			}
+		}
 	}
 	return workflow.Result{}
 }
 
+// 中间输出文件所依赖的结点中，没有执行或者执行结果不是 Ok 的结点及其执行结果
+// （没有执行时为 nil）。此时对应的中间输出文件不能作为 hack 数据
+func (r *Hack) Failed() map[string]*processor.Result {
+	return r.failed
+}
+
+// 获取的数据，由 tests 的字段映射到对应的中间输出文件
+//
+// 对应的结点没有执行时，其值为 nil
+func (r *Hack) Data() map[string]data.FileStore {
+	return r.data
+}
+
+// iomap: 由 tests 的字段映射到 workflow 的中间输出文件
+func NewHack(iomap workflow.Outbounds) *Hack {
+	return &Hack{iomap: iomap}
+}
+
 var _ workflowruntime.Analyzer = (*Hack)(nil)
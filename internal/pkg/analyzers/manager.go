@@ -17,4 +17,5 @@ func Register(name string, proc Analyzer) {
 
 func init() {
 	Register("traditional", Traditional{})
+	Register("external", External{})
 }
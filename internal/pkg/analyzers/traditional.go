@@ -126,10 +126,11 @@ func (r Traditional) Analyze(w *workflowruntime.RtWorkflow) workflow.Result {
 		}
 		return workflow.Result{
 			ResultMeta: workflow.ResultMeta{
-				Title:  "Wrong Answer",
-				Score:  0,
-				Time:   *ndRun.Result.CpuTime,
-				Memory: *ndRun.Result.Memory,
+				Title:     "Wrong Answer",
+				Score:     0,
+				Fullscore: w.Fullscore,
+				Time:      *ndRun.Result.CpuTime,
+				Memory:    *ndRun.Result.Memory,
 			},
 			File: []workflow.ResultFile{
 				fStdin,
@@ -0,0 +1,85 @@
+package judger
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// Backend actually runs the program for [Judge].
+//
+// Available backends are [Cgo] (the default one when built with cgo, needs
+// yaoj-judger), [Exec] (pure go, runs programs via os/exec with rlimits, no
+// syscall filtering), [Sandbox] (pure go, with namespaces, rlimits and cgroup
+// v2) and [BackendFunc] (e.g. returns canned results in tests).
+type Backend interface {
+	// option is completed with default values. Result.Code describes the
+	// status of the program, while error is returned only if the backend
+	// fails to run it.
+	Run(option *Option) (*Result, error)
+}
+
+// Isolating is implemented by backends that may really isolate programs run
+// with [WithIsolation], which is only the case for [Sandbox].
+type Isolating interface {
+	Backend
+	Isolates() bool
+}
+
+// Whether the backend really isolates programs run with [WithIsolation].
+func Isolates(b Backend) bool {
+	i, ok := b.(Isolating)
+	return ok && i.Isolates()
+}
+
+// BackendFunc turns a function into a [Backend].
+type BackendFunc func(option *Option) (*Result, error)
+
+func (r BackendFunc) Run(option *Option) (*Result, error) {
+	return r(option)
+}
+
+var backend = struct {
+	sync.RWMutex
+	current Backend
+}{}
+
+// Set the backend used by [Judge] and return the previous one.
+func SetBackend(b Backend) Backend {
+	backend.Lock()
+	defer backend.Unlock()
+	old := backend.current
+	backend.current = b
+	return old
+}
+
+// Get the backend used by [Judge], nil if none is available.
+func CurrentBackend() Backend {
+	backend.RLock()
+	defer backend.RUnlock()
+	return backend.current
+}
+
+// constructors of named backends, arg is the part after ":" in the spec
+var backendMakers = map[string]func(arg string) (Backend, error){}
+
+func registerBackend(name string, maker func(arg string) (Backend, error)) {
+	backendMakers[name] = maker
+}
+
+// Get a backend by its spec "<name>[:<arg>]", which is one of "cgo" (only
+// when built with cgo), "exec" and "sandbox[:<cgroup dir>]" (see
+// [Sandbox.Cgroup]). Used to select backend at startup.
+func ParseBackend(spec string) (Backend, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	maker, ok := backendMakers[name]
+	if !ok {
+		return nil, yerrors.Annotated("backend", spec, ErrUnknownBackend)
+	}
+	res, err := maker(arg)
+	if err != nil {
+		return nil, yerrors.Annotated("backend", spec, err)
+	}
+	return res, nil
+}
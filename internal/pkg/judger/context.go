@@ -1,11 +1,17 @@
+//go:build cgo && !nojudger
+
 package judger
 
 import (
 	"fmt"
+	"os"
+	"path"
+	"sync"
 	"time"
 	"unsafe"
 
 	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 //go:generate go version
@@ -17,20 +23,6 @@ import (
 //#include <stdlib.h>
 import "C"
 
-type LimitType int
-
-const (
-	realTime LimitType = C.REAL_TIME
-	cpuTime  LimitType = C.CPU_TIME
-	// virtual memory
-	virtMem  LimitType = C.VIRTUAL_MEMORY
-	realMem  LimitType = C.ACTUAL_MEMORY
-	stackMem LimitType = C.STACK_MEMORY
-	// output size
-	outputSize LimitType = C.OUTPUT_SIZE
-	filenoLim  LimitType = C.FILENO
-)
-
 // Set logging options.
 // MUST be executed before creating context.
 //
@@ -128,14 +120,6 @@ func (r context) SetRunner(argv []string, env []string) error {
 	return nil
 }
 
-type Runner int
-
-// Runner type
-const (
-	General     Runner = 0
-	Interactive Runner = 1
-)
-
 /*func (r context) Run(runner Runner) error {
 	var flag C.int
 	switch runner {
@@ -188,9 +172,6 @@ func (r context) RunForkInteractive() Result {
 	}
 }
 
-// short cut for Limitation
-type L map[LimitType]int64
-
 // it does nothing for invalid limit type
 func (r context) SetLimit(options L) error {
 	for key, val := range options {
@@ -214,8 +195,80 @@ func (r context) SetLimit(options L) error {
 	return nil
 }
 
+// Cgo is the backend based on yaoj-judger, which is the default one when
+// built with cgo. Use build tag "nojudger" to leave it out.
+//
+// yaoj-judger keeps global logging state, so runs are serialized.
+type Cgo struct{}
+
+var judgeSync sync.Mutex
+
+func (Cgo) Run(option *Option) (*Result, error) {
+	judgeSync.Lock()
+	defer judgeSync.Unlock()
+
+	if err := logSet(option.Logfile, option.LogLevel); err != nil {
+		return nil, err
+	}
+	defer logClose()
+
+	ctxt := newContext()
+	defer ctxt.Free()
+
+	if err := ctxt.SetPolicy(option.PolicyDir, option.Policy); err != nil {
+		return nil, err
+	}
+
+	if err := ctxt.SetLimit(option.Limit); err != nil {
+		return nil, err
+	}
+
+	// yaoj-judger runs the program in the current directory, and the
+	// filesystem is only protected by the policy, so isolation only changes
+	// the working directory (see [Isolates])
+	argv := option.Argument
+	if option.Isolation != nil {
+		if option.Runner != General {
+			return nil, yerrors.Annotated("runner", option.Runner, ErrUnsupported)
+		}
+		previousWd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		argv = append([]string{}, argv...)
+		for i := 0; i < 3 && i < len(argv); i++ {
+			if !path.IsAbs(argv[i]) {
+				argv[i] = path.Join(previousWd, argv[i])
+			}
+		}
+		if err := os.Chdir(option.Isolation.Dir); err != nil {
+			return nil, err
+		}
+		defer os.Chdir(previousWd)
+	}
+
+	if err := ctxt.SetRunner(argv, option.Environ); err != nil {
+		return nil, err
+	}
+
+	var result Result
+	switch option.Runner {
+	case General:
+		result = ctxt.RunForkGeneral()
+	case Interactive:
+		result = ctxt.RunForkInteractive()
+	default:
+		return nil, yerrors.Annotated("runner", option.Runner, ErrUnknownRunner)
+	}
+	return &result, nil
+}
+
 func init() {
 	if code := C.log_init(); code != 0 {
 		panic(fmt.Sprint("init log failed: ", code))
 	}
+	SetBackend(Cgo{})
+	registerBackend("cgo", func(arg string) (Backend, error) {
+		return Cgo{}, nil
+	})
 }
@@ -8,5 +8,15 @@ To build (run) this package, you need to make sure auditd, File, make,
 gengetopt, bison, xxd, strace, and clang toolkit (basically clang++) is
 available via command line. If not, install them.  Before building, run go
 generate for some necessary files.
+
+Programs are run by a [Backend]. The yaoj-judger one ([Cgo]) is used when
+built with cgo, unless the "nojudger" build tag is given. Otherwise [Exec] is
+used, which runs programs via os/exec with rlimits and needs nothing but
+/bin/sh, so that logic built upon this package can be tested with plain "go
+test" (CGO_ENABLED=0). [Sandbox] is a pure go alternative to yaoj-judger for
+deployment, isolating programs with namespaces, rlimits and cgroup v2 (but
+without syscall filtering). Use [SetBackend] to switch backend (see
+[ParseBackend] for selecting it at startup), e.g. to a [BackendFunc] returning
+canned results.
 */
 package judger
@@ -5,9 +5,12 @@ import (
 )
 
 var (
-	ErrLogSet        = yerrors.New("log_set return non zero")
-	ErrSetPolicy     = yerrors.New("set policy error")
-	ErrSetRunner     = yerrors.New("set runner error")
-	ErrUnknownRunner = yerrors.New("unknown runner")
-	ErrRun           = yerrors.New("runner runtime error")
+	ErrLogSet         = yerrors.New("log_set return non zero")
+	ErrSetPolicy      = yerrors.New("set policy error")
+	ErrSetRunner      = yerrors.New("set runner error")
+	ErrUnknownRunner  = yerrors.New("unknown runner")
+	ErrRun            = yerrors.New("runner runtime error")
+	ErrNoBackend      = yerrors.New("no judger backend available")
+	ErrUnsupported    = yerrors.New("not supported by the backend")
+	ErrUnknownBackend = yerrors.New("unknown judger backend")
 )
@@ -0,0 +1,256 @@
+package judger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// Exec is a pure go backend running programs via os/exec, which is the
+// default one when built without cgo. It's meant for testing and development:
+//
+//   - Rlimits are set by /bin/sh (ulimit) before executing the program.
+//   - Policies are ignored, i.e. there is no syscall filtering.
+//   - Isolation only changes the working directory.
+//   - Real memory is only checked (by max RSS) after the program exits.
+//   - Only the General runner is supported.
+type Exec struct{}
+
+// shell used to set rlimits
+const execShell = "/bin/sh"
+
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
+
+// ulimit commands of the limitations
+func ulimitCmds(lim L) []string {
+	var res []string
+	add := func(key LimitType, flag string, unit int64) {
+		if val, ok := lim[key]; ok {
+			res = append(res, fmt.Sprintf("ulimit -%s %d", flag, ceilDiv(val, unit)))
+		}
+	}
+	add(cpuTime, "t", 1000)
+	add(virtMem, "v", int64(KB))
+	add(stackMem, "s", int64(KB))
+	add(outputSize, "f", 512)
+	add(filenoLim, "n", 1)
+	return res
+}
+
+func (Exec) Run(option *Option) (*Result, error) {
+	return runGeneral(option, execGeneral)
+}
+
+// check the option and run the program with a general runner, logging to
+// option.Logfile
+func runGeneral(option *Option, run func(option *Option) *Result) (*Result, error) {
+	if option.Runner != General {
+		return nil, yerrors.Annotated("runner", option.Runner, ErrUnsupported)
+	}
+	if len(option.Argument) < 4 {
+		return nil, yerrors.Annotated("argument", option.Argument, ErrSetRunner)
+	}
+	var logger io.Writer = io.Discard
+	if option.Logfile != "" {
+		file, err := os.Create(option.Logfile)
+		if err != nil {
+			return nil, yerrors.Situated("logfile", ErrLogSet)
+		}
+		defer file.Close()
+		logger = file
+	}
+	fmt.Fprintln(logger, "exec:", option.Argument)
+	res := run(option)
+	fmt.Fprintln(logger, "result:", res)
+	return res, nil
+}
+
+func sysErrResult(err error) *Result {
+	return &Result{Code: processor.SystemError, Msg: err.Error()}
+}
+
+// open input, output and error output of the general runner
+func openStdio(argv []string) ([]*os.File, error) {
+	files := make([]*os.File, 3)
+	flags := []int{os.O_RDONLY, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, os.O_WRONLY | os.O_CREATE | os.O_TRUNC}
+	for i := range files {
+		file, err := os.OpenFile(argv[i], flags[i], 0644)
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files[i] = file
+	}
+	return files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		if file != nil {
+			file.Close()
+		}
+	}
+}
+
+// status of an exited program
+type execStatus struct {
+	status            syscall.WaitStatus
+	realtime, cputime time.Duration
+	memory            ByteValue
+	// killed for exceeding real time limit
+	killed bool
+	// killed by oom killer
+	oom bool
+	// some output reaches the limit
+	outputFull bool
+}
+
+// whether some of the output files reaches the output limit
+func outputFull(files []*os.File, lim L) bool {
+	val, ok := lim[outputSize]
+	if !ok {
+		return false
+	}
+	for _, file := range files {
+		if stat, err := file.Stat(); err == nil && stat.Mode().IsRegular() && stat.Size() >= val {
+			return true
+		}
+	}
+	return false
+}
+
+func (r execStatus) result(lim L) *Result {
+	signal := 0
+	msg := fmt.Sprintf("Exit with code %d", r.status.ExitStatus())
+	if r.status.Signaled() {
+		signal = int(r.status.Signal())
+		msg = fmt.Sprintf("Killed by signal %d (%v)", signal, r.status.Signal())
+	}
+	exceeds := func(key LimitType, val int64) bool {
+		limit, ok := lim[key]
+		return ok && val > limit
+	}
+
+	code := processor.Ok
+	switch {
+	case r.killed, exceeds(realTime, r.realtime.Milliseconds()),
+		exceeds(cpuTime, r.cputime.Milliseconds()), r.status.Signal() == syscall.SIGXCPU:
+		code = processor.TimeExceed
+	case r.oom, exceeds(realMem, int64(r.memory)):
+		code = processor.MemoryExceed
+	case r.outputFull, r.status.Signal() == syscall.SIGXFSZ:
+		code = processor.OutputExceed
+	case r.status.Signaled():
+		code = processor.RuntimeError
+	case r.status.ExitStatus() != 0:
+		code = processor.ExitError
+	}
+	return &Result{
+		Code:     code,
+		Signal:   &signal,
+		Msg:      msg,
+		RealTime: &r.realtime,
+		CpuTime:  &r.cputime,
+		Memory:   &r.memory,
+	}
+}
+
+// kill the process group of cmd after the real time limit (if any), the
+// returned function stops the timer and reports whether it's killed
+func killAfter(cmd *exec.Cmd, lim L, kill func()) (stop func() bool) {
+	val, ok := lim[realTime]
+	if !ok {
+		return func() bool { return false }
+	}
+	var killed int32
+	timer := time.AfterFunc(time.Duration(val)*time.Millisecond, func() {
+		atomic.StoreInt32(&killed, 1)
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		if kill != nil {
+			kill()
+		}
+	})
+	return func() bool {
+		timer.Stop()
+		return atomic.LoadInt32(&killed) == 1
+	}
+}
+
+// status from cmd.ProcessState, using rusage for cpu time and memory
+func waitStatus(cmd *exec.Cmd, start time.Time, err error) (execStatus, error) {
+	realtime := time.Since(start)
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return execStatus{}, err
+	}
+	state := cmd.ProcessState
+	res := execStatus{
+		status:   state.Sys().(syscall.WaitStatus),
+		realtime: realtime,
+		cputime:  state.UserTime() + state.SystemTime(),
+	}
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		res.memory = ByteValue(usage.Maxrss) * KB
+	}
+	return res, nil
+}
+
+func execGeneral(option *Option) *Result {
+	argv, lim := option.Argument, option.Limit
+	files, err := openStdio(argv)
+	if err != nil {
+		return sysErrResult(err)
+	}
+	defer closeFiles(files)
+
+	prog := argv[3]
+	if !strings.Contains(prog, "/") {
+		prog = "./" + prog
+	}
+	script := strings.Join(append(ulimitCmds(lim), `exec "$@"`), " && ")
+	cmd := &exec.Cmd{
+		Path:        execShell,
+		Args:        append([]string{"sh", "-c", script, "sh", prog}, argv[4:]...),
+		Env:         option.Environ,
+		Stdin:       files[0],
+		Stdout:      files[1],
+		Stderr:      files[2],
+		SysProcAttr: &syscall.SysProcAttr{Setpgid: true},
+	}
+	if option.Isolation != nil {
+		cmd.Dir = option.Isolation.Dir
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return sysErrResult(err)
+	}
+	stop := killAfter(cmd, lim, nil)
+	err = cmd.Wait()
+	killed := stop()
+	status, err := waitStatus(cmd, start, err)
+	if err != nil {
+		return sysErrResult(err)
+	}
+	status.killed = killed
+	status.outputFull = outputFull(files[1:], lim)
+	return status.result(lim)
+}
+
+func init() {
+	if CurrentBackend() == nil {
+		SetBackend(Exec{})
+	}
+	registerBackend("exec", func(arg string) (Backend, error) {
+		return Exec{}, nil
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/super-yaoj/yaoj-core/pkg/log"
 	"github.com/super-yaoj/yaoj-core/pkg/processor"
 	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 type Option struct {
@@ -20,12 +21,51 @@ type Option struct {
 	Environ   []string
 	Limit     L
 	Runner    Runner
+	// nil for running in the current directory
+	Isolation *Isolation
 }
 
+// Isolation of a run, see [WithIsolation].
+type Isolation struct {
+	// Private working directory (absolute path), which is the only writable
+	// directory visible to the program (at the same path).
+	Dir string
+	// Directories or files (absolute paths) visible to the program read-only.
+	ReadOnly []string
+}
+
+// Read-only directories of the toolchain, skipped if not exist.
+var DefaultReadOnly = []string{"/bin", "/lib", "/lib32", "/lib64", "/usr"}
+
 type OptionProvider func(*Option)
 
 type ByteValue int64
 
+type LimitType int
+
+const (
+	realTime LimitType = iota
+	cpuTime
+	// virtual memory
+	virtMem
+	realMem
+	stackMem
+	// output size
+	outputSize
+	filenoLim
+)
+
+// short cut for Limitation
+type L map[LimitType]int64
+
+type Runner int
+
+// Runner type
+const (
+	General     Runner = 0
+	Interactive Runner = 1
+)
+
 const KB ByteValue = 1024
 const MB ByteValue = KB * KB
 const GB ByteValue = KB * MB
@@ -73,12 +113,7 @@ func (r *Result) ProcResult() *processor.Result {
 	return &res
 }
 
-var judgeSync sync.Mutex
-
 func Judge(options ...OptionProvider) (*Result, error) {
-	judgeSync.Lock()
-	defer judgeSync.Unlock()
-
 	var option = Option{
 		Environ:   os.Environ(),
 		Policy:    "builtin:free",
@@ -96,38 +131,23 @@ func Judge(options ...OptionProvider) (*Result, error) {
 	logger := log.NewTerminal().WithField("runner", option.Runner)
 	logger.Debug(option.Argument)
 
-	if err := logSet(option.Logfile, option.LogLevel); err != nil {
-		return nil, err
+	if option.Runner != General && option.Runner != Interactive {
+		return nil, yerrors.Annotated("runner", option.Runner, ErrUnknownRunner)
 	}
-	defer logClose()
-
-	ctxt := newContext()
-	defer ctxt.Free()
-
-	if err := ctxt.SetPolicy(option.PolicyDir, option.Policy); err != nil {
-		return nil, err
+	backend := CurrentBackend()
+	if backend == nil {
+		return nil, ErrNoBackend
 	}
-
-	if err := ctxt.SetLimit(option.Limit); err != nil {
-		return nil, err
-	}
-
-	if err := ctxt.SetRunner(option.Argument, option.Environ); err != nil {
-		return nil, err
+	if option.Isolation != nil && !Isolates(backend) {
+		isolationWarning.Do(func() {
+			logger.Warnf("backend %T does not isolate programs, which only run in the private directory", backend)
+		})
 	}
-
-	var result Result
-	switch option.Runner {
-	case General:
-		result = ctxt.RunForkGeneral()
-	case Interactive:
-		result = ctxt.RunForkInteractive()
-	default:
-		return nil, fmt.Errorf("invalid runner")
-	}
-	return &result, nil
+	return backend.Run(&option)
 }
 
+var isolationWarning sync.Once
+
 // Runners differ in arguments.
 //
 // For the General: [input] [output] [outerr] [exec] [arguments...]
@@ -141,6 +161,24 @@ func WithArgument(argv ...string) OptionProvider {
 	}
 }
 
+// Run the program in a private directory dir (absolute path). If supported by
+// the backend (see [Isolates]), the program only sees dir, readonly paths
+// (default: [DefaultReadOnly]) and a few devices (e.g. /dev/null), and has no
+// network. Other backends just run the program in dir, where it can still
+// access other files and the network, and a warning is logged (once).
+//
+// Relative paths of the program are resolved in dir, while those of stdin,
+// stdout and stderr are opened by the backend (in the current directory).
+// Outputs written to dir are collected by the caller afterwards.
+func WithIsolation(dir string, readonly ...string) OptionProvider {
+	if len(readonly) == 0 {
+		readonly = DefaultReadOnly
+	}
+	return func(o *Option) {
+		o.Isolation = &Isolation{Dir: dir, ReadOnly: readonly}
+	}
+}
+
 // default: os.Environ()
 func WithEnviron(environ ...string) OptionProvider {
 	return func(o *Option) {
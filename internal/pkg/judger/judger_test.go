@@ -1,8 +1,10 @@
 package judger_test
 
 import (
+	"errors"
 	"os"
 	"path"
+	"syscall"
 	"testing"
 	"time"
 
@@ -35,3 +37,195 @@ func TestJudge(t *testing.T) {
 	}
 	t.Log(*res, res.ProcResult())
 }
+
+// run a shell script with the backend
+func execScript(t *testing.T, backend judger.Backend, script string, options ...judger.OptionProvider) *judger.Result {
+	old := judger.SetBackend(backend)
+	t.Cleanup(func() { judger.SetBackend(old) })
+
+	dir := t.TempDir()
+	options = append([]judger.OptionProvider{
+		judger.WithArgument("/dev/null", path.Join(dir, "output"), path.Join(dir, "outerr"), "/bin/sh", "-c", script),
+		judger.WithLog(path.Join(dir, "runtime.log"), 0),
+	}, options...)
+	res, err := judger.Judge(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestExec(t *testing.T) {
+	res := execScript(t, judger.Exec{}, "echo hello")
+	if res.Code != processor.Ok || *res.Signal != 0 {
+		t.Fatal("invalid result", res)
+	}
+	if res := execScript(t, judger.Exec{}, "exit 3"); res.Code != processor.ExitError {
+		t.Fatal("expect exit error", res)
+	}
+	if res := execScript(t, judger.Exec{}, "kill -SEGV $$"); res.Code != processor.RuntimeError || *res.Signal != 11 {
+		t.Fatal("expect runtime error", res)
+	}
+	res = execScript(t, judger.Exec{}, "sleep 5", judger.WithRealTime(200*time.Millisecond))
+	if res.Code != processor.TimeExceed || *res.RealTime > 2*time.Second {
+		t.Fatal("expect time exceed", res)
+	}
+	if res := execScript(t, judger.Exec{}, "while :; do :; done", judger.WithCpuTime(100*time.Millisecond),
+		judger.WithRealTime(5*time.Second)); res.Code != processor.TimeExceed {
+		t.Fatal("expect time exceed", res)
+	}
+	if res := execScript(t, judger.Exec{}, "exec head -c 100000 /dev/zero", judger.WithOutput(4*judger.KB)); res.Code != processor.OutputExceed {
+		t.Fatal("expect output exceed", res)
+	}
+	if res := execScript(t, judger.Exec{}, "echo hello", judger.WithRealMemory(judger.KB)); res.Code != processor.MemoryExceed {
+		t.Fatal("expect memory exceed", res)
+	}
+
+	old := judger.SetBackend(judger.Exec{})
+	defer judger.SetBackend(old)
+	if _, err := judger.Judge(judger.WithJudger(judger.Interactive)); !errors.Is(err, judger.ErrUnsupported) {
+		t.Fatal("expect unsupported", err)
+	}
+}
+
+func TestBackendFunc(t *testing.T) {
+	var argv []string
+	old := judger.SetBackend(judger.BackendFunc(func(option *judger.Option) (*judger.Result, error) {
+		argv = option.Argument
+		return &judger.Result{Code: processor.MemoryExceed}, nil
+	}))
+	defer judger.SetBackend(old)
+
+	res, err := judger.Judge(judger.WithArgument("a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != processor.MemoryExceed || len(argv) != 2 {
+		t.Fatal("invalid result", res, argv)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root required")
+	}
+	// e.g. /sys/fs/cgroup/yaoj, which must exist
+	cgroup := os.Getenv("YAOJ_TEST_CGROUP")
+	sandbox := judger.NewSandbox(cgroup)
+	// without pid namespace so that the program can kill itself
+	nopid := judger.Sandbox{Cloneflags: syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS, Cgroup: cgroup}
+
+	res := execScript(t, sandbox, "echo $$ > /dev/stderr; hostname", judger.WithEnviron())
+	if res.Code != processor.Ok || *res.Signal != 0 {
+		t.Fatal("invalid result", res)
+	}
+	if res := execScript(t, sandbox, "exit 3"); res.Code != processor.ExitError {
+		t.Fatal("expect exit error", res)
+	}
+	if res := execScript(t, nopid, "kill -SEGV $$"); res.Code != processor.RuntimeError || *res.Signal != 11 {
+		t.Fatal("expect runtime error", res)
+	}
+	res = execScript(t, sandbox, "sleep 5", judger.WithRealTime(200*time.Millisecond))
+	if res.Code != processor.TimeExceed || *res.RealTime > 2*time.Second {
+		t.Fatal("expect time exceed", res)
+	}
+	if res := execScript(t, sandbox, "while :; do :; done", judger.WithCpuTime(100*time.Millisecond),
+		judger.WithRealTime(5*time.Second)); res.Code != processor.TimeExceed {
+		t.Fatal("expect time exceed", res)
+	}
+	if res := execScript(t, sandbox, "exec head -c 100000 /dev/zero", judger.WithOutput(4*judger.KB)); res.Code != processor.OutputExceed {
+		t.Fatal("expect output exceed", res)
+	}
+	if res := execScript(t, sandbox, "echo hello", judger.WithRealMemory(judger.KB)); res.Code != processor.MemoryExceed {
+		t.Fatal("expect memory exceed", res)
+	}
+	if res := execScript(t, sandbox, "exec cat /dev/null", judger.WithFileno(2)); res.Code != processor.ExitError {
+		t.Fatal("expect exit error for too many files", res)
+	}
+	// unprivileged, without capabilities, and cannot gain privileges
+	privileges := "grep -q '^CapEff:[[:space:]]*0*$' /proc/self/status && " +
+		"grep -q '^CapBnd:[[:space:]]*0*$' /proc/self/status && " +
+		"grep -q '^NoNewPrivs:[[:space:]]*1$' /proc/self/status"
+	if res := execScript(t, sandbox, "test \"$(id -u)\" != 0 && test \"$(id -g)\" != 0 && "+privileges,
+		judger.WithPolicy("builtin:yaoj")); res.Code != processor.Ok {
+		t.Fatal("expect unprivileged user", res)
+	}
+	if res := execScript(t, sandbox, privileges); res.Code != processor.Ok {
+		t.Fatal("expect no capabilities", res)
+	}
+	// trusted programs processing untrusted input
+	if res := execScript(t, sandbox, "test \"$(id -u)\" != 0 && "+privileges,
+		judger.WithIsolation(t.TempDir())); res.Code != processor.Ok {
+		t.Fatal("expect unprivileged user with isolation", res)
+	}
+
+	old := judger.SetBackend(sandbox)
+	defer judger.SetBackend(old)
+	dir := t.TempDir()
+	res, err := judger.Judge(
+		judger.WithArgument("/dev/null", path.Join(dir, "output"), "/dev/null", path.Join(dir, "notexist")),
+		judger.WithLog(path.Join(dir, "runtime.log"), 0),
+	)
+	if err != nil || res.Code != processor.SystemError {
+		t.Fatal("expect system error", res, err)
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	if backend, err := judger.ParseBackend("sandbox:/sys/fs/cgroup/yaoj"); err != nil ||
+		backend.(judger.Sandbox).Cgroup != "/sys/fs/cgroup/yaoj" {
+		t.Fatal("invalid backend", backend, err)
+	}
+	if backend, err := judger.ParseBackend("exec"); err != nil || backend != (judger.Exec{}) {
+		t.Fatal("invalid backend", backend, err)
+	}
+	if _, err := judger.ParseBackend("docker"); !errors.Is(err, judger.ErrUnknownBackend) {
+		t.Fatal("expect unknown backend", err)
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	dir, secret := t.TempDir(), path.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	isolation := judger.WithIsolation(dir)
+	sandbox := judger.NewSandbox("")
+	if judger.Isolates(judger.Exec{}) || !judger.Isolates(sandbox) || !judger.Isolates(&sandbox) {
+		t.Fatal("only sandbox isolates programs")
+	}
+
+	res := execScript(t, judger.Exec{}, "test \"$(pwd)\" = "+dir, isolation)
+	if res.Code != processor.Ok {
+		t.Fatal("invalid result", res)
+	}
+
+	if os.Geteuid() != 0 {
+		t.Skip("root required")
+	}
+	// writes to the private directory, cannot see other files, cannot write
+	// to the toolchain, and has only loopback network device
+	script := "echo ok > out.txt && test ! -e " + secret + " && test ! -e /etc/passwd && " +
+		"! touch /x /usr/x 2>/dev/null && tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' '"
+	old := judger.SetBackend(judger.NewSandbox(os.Getenv("YAOJ_TEST_CGROUP")))
+	defer judger.SetBackend(old)
+	output := path.Join(t.TempDir(), "output")
+	res, err := judger.Judge(
+		judger.WithArgument("/dev/null", output, "/dev/null", "/bin/sh", "-c", script),
+		judger.WithLog("", 0),
+		judger.WithPolicy("builtin:yaoj"),
+		isolation,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != processor.Ok {
+		t.Fatal("invalid result", res)
+	}
+	if content, _ := os.ReadFile(output); string(content) != "lo\n" {
+		t.Fatalf("invalid output %q", content)
+	}
+	if content, _ := os.ReadFile(path.Join(dir, "out.txt")); string(content) != "ok\n" {
+		t.Fatalf("invalid file output %q", content)
+	}
+}
@@ -0,0 +1,551 @@
+package judger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Sandbox is a pure go backend isolating programs with linux namespaces,
+// setrlimit and (optionally) cgroup v2. Root privilege is required to create
+// the namespaces and cgroups.
+//
+// The program is started by re-executing the current binary
+// (/proc/self/exe), which joins the cgroup, sets rlimits and drops privileges
+// in the init function of this package before executing the program, thus the
+// binary must import this package. Note that the program is the init process of the new
+// pid namespace, so signals sent by itself without handlers (including
+// SIGXFSZ) are ignored. Thus output limit exceeding is also detected by the
+// size of output files.
+//
+// With [WithIsolation], the program additionally runs in new mount and network
+// namespaces, in a read-only tmpfs root containing only the private directory,
+// the read-only paths and a few devices (plus /proc in a pid namespace).
+//
+// The program never keeps any capability: the bounding and ambient sets are
+// cleared and no_new_privs is set before executing it. It runs as an
+// unprivileged user ([Sandbox.Uid], [Sandbox.Gid]), who owns the private
+// directory of [WithIsolation]. The only exception is policy "builtin:free"
+// without [WithIsolation], which is for trusted programs (e.g. checkers)
+// writing files in the working directory, where the program runs as root
+// without capabilities. Programs processing untrusted input (e.g. compilers of
+// submissions) must run with [WithIsolation], where they cannot read files
+// of root either. Other policies are ignored. Only the General runner is
+// supported.
+type Sandbox struct {
+	// Clone flags of the namespaces to create, see [SandboxCloneflags].
+	Cloneflags uintptr
+	// Parent cgroup (v2) directory, e.g. "/sys/fs/cgroup/yaoj", where a
+	// cgroup is created for each run to limit memory (memory.max, if the
+	// memory controller is enabled), account cpu time (cpu.stat) and memory
+	// (memory.peak), and kill the remaining processes. Empty for no cgroup,
+	// where cpu time and memory come from rusage, the latter includes the
+	// memory used by the starter (a few MB).
+	Cgroup string
+	// User and group running the program, 0 for [SandboxUid] and
+	// [SandboxGid]. Running as root is not allowed.
+	Uid, Gid int
+}
+
+// pid, ipc, uts and mount namespaces
+const SandboxCloneflags = syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS | syscall.CLONE_NEWNS
+
+// Default user and group (nobody) running the program.
+const (
+	SandboxUid = 65534
+	SandboxGid = 65534
+)
+
+// Sandbox with default namespaces.
+func NewSandbox(cgroup string) Sandbox {
+	return Sandbox{Cloneflags: SandboxCloneflags, Cgroup: cgroup}
+}
+
+const (
+	// argv[0] of the starter
+	sandboxInit = "yaoj-sandbox-init"
+	// environment variable marking the starter
+	sandboxInitEnv = "YAOJ_SANDBOX_INIT"
+	// file descriptors (ExtraFiles) of the configuration and the error report
+	sandboxConfFd = 3
+	sandboxErrFd  = 4
+)
+
+// configuration passed to the starter
+type sandboxConf struct {
+	Argv    []string        `json:"argv"`
+	Env     []string        `json:"env"`
+	Rlimits []sandboxRlimit `json:"rlimits"`
+	// cgroup directory to join, empty for none
+	Cgroup string `json:"cgroup"`
+	// new root directory (in a mount namespace), empty for no isolation
+	Root      string     `json:"root"`
+	Isolation *Isolation `json:"isolation"`
+	// mount /proc (in a pid namespace)
+	Proc bool `json:"proc"`
+	// user and group to switch to, 0 for keeping root (without capabilities)
+	Uid int `json:"uid"`
+	Gid int `json:"gid"`
+}
+
+type sandboxRlimit struct {
+	Resource int    `json:"resource"`
+	Cur      uint64 `json:"cur"`
+	Max      uint64 `json:"max"`
+}
+
+// rlimits of the limitations
+func sandboxRlimits(lim L) []sandboxRlimit {
+	var res []sandboxRlimit
+	add := func(key LimitType, resource int, unit int64, extra uint64) {
+		if val, ok := lim[key]; ok {
+			cur := uint64(ceilDiv(val, unit))
+			res = append(res, sandboxRlimit{resource, cur, cur + extra})
+		}
+	}
+	// SIGXCPU at the soft limit, SIGKILL at the hard limit
+	add(cpuTime, syscall.RLIMIT_CPU, 1000, 1)
+	add(virtMem, syscall.RLIMIT_AS, 1, 0)
+	add(stackMem, syscall.RLIMIT_STACK, 1, 0)
+	add(outputSize, syscall.RLIMIT_FSIZE, 1, 0)
+	add(filenoLim, syscall.RLIMIT_NOFILE, 1, 0)
+	return res
+}
+
+func (r Sandbox) Run(option *Option) (*Result, error) {
+	return runGeneral(option, r.general)
+}
+
+// Sandbox really isolates programs run with [WithIsolation].
+func (r Sandbox) Isolates() bool {
+	return true
+}
+
+// user and group running the program with the policy
+func (r Sandbox) user(policy string, isolated bool) (uid, gid int) {
+	if policy == "builtin:free" && !isolated {
+		return 0, 0
+	}
+	uid, gid = r.Uid, r.Gid
+	if uid == 0 {
+		uid = SandboxUid
+	}
+	if gid == 0 {
+		gid = SandboxGid
+	}
+	return uid, gid
+}
+
+// cgroup of a run
+type sandboxCgroup string
+
+func (r sandboxCgroup) file(name string) string {
+	return path.Join(string(r), name)
+}
+
+// write value to the file if it exists
+func (r sandboxCgroup) set(name string, value string) error {
+	file, err := os.OpenFile(r.file(name), os.O_WRONLY|os.O_TRUNC, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(value)
+	return err
+}
+
+// value of key in a flat keyed file (e.g. cpu.stat), -1 if unavailable
+func (r sandboxCgroup) stat(name string, key string) int64 {
+	content, err := os.ReadFile(r.file(name))
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == key {
+			if val, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return val
+			}
+		}
+	}
+	return -1
+}
+
+// value of a single value file (e.g. memory.peak), -1 if unavailable
+func (r sandboxCgroup) value(name string) int64 {
+	content, err := os.ReadFile(r.file(name))
+	if err != nil {
+		return -1
+	}
+	val, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return val
+}
+
+// kill all processes in it
+func (r sandboxCgroup) kill() {
+	r.set("cgroup.kill", "1")
+}
+
+// kill all processes and remove it
+func (r sandboxCgroup) remove() {
+	r.kill()
+	for i := 0; i < 100; i++ {
+		if err := os.Remove(string(r)); err == nil || errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (r Sandbox) newCgroup(lim L) (sandboxCgroup, error) {
+	dir, err := os.MkdirTemp(r.Cgroup, "run-")
+	if err != nil {
+		return "", err
+	}
+	cg := sandboxCgroup(dir)
+	if val, ok := lim[realMem]; ok {
+		if err := cg.set("memory.max", fmt.Sprint(val)); err != nil {
+			cg.remove()
+			return "", err
+		}
+		if err := cg.set("memory.swap.max", "0"); err != nil {
+			cg.remove()
+			return "", err
+		}
+	}
+	return cg, nil
+}
+
+func (r Sandbox) general(option *Option) *Result {
+	argv, lim := option.Argument, option.Limit
+	files, err := openStdio(argv)
+	if err != nil {
+		return sysErrResult(err)
+	}
+	defer closeFiles(files)
+
+	conf := sandboxConf{
+		Argv:    argv[3:],
+		Env:     option.Environ,
+		Rlimits: sandboxRlimits(lim),
+	}
+	conf.Uid, conf.Gid = r.user(option.Policy, option.Isolation != nil)
+	cloneflags := r.Cloneflags
+	if option.Isolation != nil {
+		if !path.IsAbs(option.Isolation.Dir) {
+			return sysErrResult(fmt.Errorf("isolation dir %q is not absolute", option.Isolation.Dir))
+		}
+		// mount point of the new root, which is empty outside the namespace
+		root, err := os.MkdirTemp("", "yaoj-sandbox-")
+		if err != nil {
+			return sysErrResult(err)
+		}
+		defer os.Remove(root)
+		// the only writable directory of the program
+		if err := os.Chown(option.Isolation.Dir, conf.Uid, conf.Gid); err != nil {
+			return sysErrResult(err)
+		}
+		conf.Root = root
+		conf.Isolation = option.Isolation
+		conf.Proc = cloneflags&syscall.CLONE_NEWPID != 0
+		cloneflags |= syscall.CLONE_NEWNS | syscall.CLONE_NEWNET
+	}
+	var cg sandboxCgroup
+	if r.Cgroup != "" {
+		if cg, err = r.newCgroup(lim); err != nil {
+			return sysErrResult(err)
+		}
+		defer cg.remove()
+		conf.Cgroup = string(cg)
+	}
+
+	confR, confW, err := os.Pipe()
+	if err != nil {
+		return sysErrResult(err)
+	}
+	defer confW.Close()
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		confR.Close()
+		return sysErrResult(err)
+	}
+	defer errR.Close()
+
+	cmd := &exec.Cmd{
+		Path:       "/proc/self/exe",
+		Args:       []string{sandboxInit},
+		Env:        []string{sandboxInitEnv + "=1"},
+		Stdin:      files[0],
+		Stdout:     files[1],
+		Stderr:     files[2],
+		ExtraFiles: []*os.File{confR, errW},
+		SysProcAttr: &syscall.SysProcAttr{
+			Setpgid:    true,
+			Cloneflags: cloneflags,
+			Pdeathsig:  syscall.SIGKILL,
+		},
+	}
+	start := time.Now()
+	err = cmd.Start()
+	confR.Close()
+	errW.Close()
+	if err != nil {
+		return sysErrResult(err)
+	}
+	stop := killAfter(cmd, lim, func() {
+		if cg != "" {
+			cg.kill()
+		}
+	})
+
+	// the error pipe is closed on exec, or reports why the starter fails
+	json.NewEncoder(confW).Encode(conf)
+	confW.Close()
+	report, _ := io.ReadAll(errR)
+
+	err = cmd.Wait()
+	killed := stop()
+	if len(report) > 0 {
+		return sysErrResult(fmt.Errorf("sandbox: %s", report))
+	}
+	status, err := waitStatus(cmd, start, err)
+	if err != nil {
+		return sysErrResult(err)
+	}
+	status.killed = killed
+	status.outputFull = outputFull(files[1:], lim)
+	if cg != "" {
+		if usec := cg.stat("cpu.stat", "usage_usec"); usec >= 0 {
+			status.cputime = time.Duration(usec) * time.Microsecond
+		}
+		if peak := cg.value("memory.peak"); peak >= 0 {
+			status.memory = ByteValue(peak)
+		}
+		status.oom = cg.stat("memory.events", "oom_kill") > 0
+	}
+	return status.result(lim)
+}
+
+// devices visible to the isolated program
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+// run by the starter: build the new root with the private directory, readonly
+// paths and devices, then pivot to it
+func sandboxIsolate(conf *sandboxConf) error {
+	mount := func(source, target, fstype string, flags uintptr, data string) error {
+		if err := syscall.Mount(source, target, fstype, flags, data); err != nil {
+			return fmt.Errorf("mount %s: %w", target, err)
+		}
+		return nil
+	}
+	root := conf.Root
+	// bind source to the same path in the new root
+	bind := func(source string, flags uintptr) error {
+		stat, err := os.Lstat(source)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		target := path.Join(root, source)
+		if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+			return err
+		}
+		switch {
+		case stat.Mode()&os.ModeSymlink != 0: // e.g. /bin -> usr/bin
+			link, err := os.Readlink(source)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case stat.IsDir():
+			err = os.MkdirAll(target, 0755)
+		default:
+			err = os.WriteFile(target, nil, 0644)
+		}
+		if err != nil {
+			return err
+		}
+		if err := mount(source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return err
+		}
+		if flags != 0 {
+			return mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|flags, "")
+		}
+		return nil
+	}
+
+	// not to propagate mounts to the host
+	if err := mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return err
+	}
+	if err := mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=755"); err != nil {
+		return err
+	}
+	for _, dir := range conf.Isolation.ReadOnly {
+		if err := bind(dir, syscall.MS_RDONLY|syscall.MS_NOSUID); err != nil {
+			return err
+		}
+	}
+	for _, dev := range sandboxDevices {
+		if err := bind(dev, 0); err != nil {
+			return err
+		}
+	}
+	if err := bind(conf.Isolation.Dir, syscall.MS_NOSUID|syscall.MS_NODEV); err != nil {
+		return err
+	}
+	if conf.Proc {
+		target := path.Join(root, "proc")
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		if err := mount("proc", target, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+			return err
+		}
+	}
+
+	old := path.Join(root, ".old")
+	if err := os.Mkdir(old, 0700); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(root, old); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := syscall.Chdir("/"); err != nil {
+		return err
+	}
+	if err := syscall.Unmount("/.old", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %w", err)
+	}
+	if err := os.Remove("/.old"); err != nil {
+		return err
+	}
+	if err := mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		return err
+	}
+	return syscall.Chdir(conf.Isolation.Dir)
+}
+
+const (
+	prSetNoNewPrivs      = 38
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+	// larger than any capability number
+	capMax = 64
+)
+
+func prctl(option int, arg2 uintptr) syscall.Errno {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, uintptr(option), arg2, 0)
+	return errno
+}
+
+// run by the starter: drop all capabilities (of the current thread, which
+// executes the program) and switch to the user and group
+func sandboxDropPrivileges(conf *sandboxConf) error {
+	for cap := 0; cap < capMax; cap++ {
+		// EINVAL for capabilities unknown to the kernel
+		if errno := prctl(syscall.PR_CAPBSET_DROP, uintptr(cap)); errno != 0 && errno != syscall.EINVAL {
+			return fmt.Errorf("drop capability %d: %w", cap, errno)
+		}
+	}
+	if conf.Uid != 0 {
+		if err := syscall.Setgroups(nil); err != nil {
+			return fmt.Errorf("setgroups: %w", err)
+		}
+		if err := syscall.Setresgid(conf.Gid, conf.Gid, conf.Gid); err != nil {
+			return fmt.Errorf("setresgid: %w", err)
+		}
+		if err := syscall.Setresuid(conf.Uid, conf.Uid, conf.Uid); err != nil {
+			return fmt.Errorf("setresuid: %w", err)
+		}
+	}
+	// EINVAL for kernels without ambient capabilities (< 4.3)
+	if errno := prctl(prCapAmbient, prCapAmbientClearAll); errno != 0 && errno != syscall.EINVAL {
+		return fmt.Errorf("clear ambient capabilities: %w", errno)
+	}
+	if errno := prctl(prSetNoNewPrivs, 1); errno != 0 {
+		return fmt.Errorf("set no_new_privs: %w", errno)
+	}
+	return nil
+}
+
+// run by the starter: join the cgroup, isolate, set rlimits, drop privileges
+// and execute the program
+func sandboxExec(conffile *os.File) error {
+	var conf sandboxConf
+	if err := json.NewDecoder(conffile).Decode(&conf); err != nil {
+		return err
+	}
+	conffile.Close()
+	if len(conf.Argv) == 0 {
+		return errors.New("empty argv")
+	}
+	if conf.Cgroup != "" {
+		if err := os.WriteFile(path.Join(conf.Cgroup, "cgroup.procs"), []byte("0"), 0); err != nil {
+			return err
+		}
+	}
+	if conf.Root != "" {
+		if err := sandboxIsolate(&conf); err != nil {
+			return err
+		}
+	}
+	// prepared before setting rlimits, since allocation may fail after it
+	argv0, err := syscall.BytePtrFromString(conf.Argv[0])
+	if err != nil {
+		return err
+	}
+	argv, err := syscall.SlicePtrFromStrings(conf.Argv)
+	if err != nil {
+		return err
+	}
+	envv, err := syscall.SlicePtrFromStrings(conf.Env)
+	if err != nil {
+		return err
+	}
+	for _, lim := range conf.Rlimits {
+		rlimit := syscall.Rlimit{Cur: lim.Cur, Max: lim.Max}
+		if err := syscall.Setrlimit(lim.Resource, &rlimit); err != nil {
+			return fmt.Errorf("setrlimit %d: %w", lim.Resource, err)
+		}
+	}
+	// after setrlimit, which may raise the hard limits
+	if err := sandboxDropPrivileges(&conf); err != nil {
+		return err
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE,
+		uintptr(unsafe.Pointer(argv0)),
+		uintptr(unsafe.Pointer(&argv[0])),
+		uintptr(unsafe.Pointer(&envv[0])))
+	return fmt.Errorf("execve %s: %w", conf.Argv[0], errno)
+}
+
+func init() {
+	if len(os.Args) > 0 && os.Args[0] == sandboxInit && os.Getenv(sandboxInitEnv) == "1" {
+		// capabilities are per thread, so the thread dropping them must be
+		// the one executing the program
+		runtime.LockOSThread()
+		syscall.CloseOnExec(sandboxErrFd)
+		err := sandboxExec(os.NewFile(sandboxConfFd, "conf"))
+		os.NewFile(sandboxErrFd, "error").WriteString(err.Error())
+		os.Exit(127)
+	}
+	registerBackend("sandbox", func(arg string) (Backend, error) {
+		return NewSandbox(arg), nil
+	})
+}
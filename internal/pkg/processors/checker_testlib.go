@@ -1,6 +1,7 @@
 package processors
 
 import (
+	"os"
 	"time"
 
 	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
@@ -17,7 +18,11 @@ func (r CheckerTestlib) Label() (inputlabel []string, outputlabel []string) {
 	return []string{"checker", "input", "output", "answer"},
 		[]string{"xmlreport", "stderr", "judgerlog"}
 }
-func (r CheckerTestlib) Process(inputs Inbounds, outputs Outbounds) (result *Result) {
+
+func (r CheckerTestlib) Version() string {
+	return "1"
+}
+func (r CheckerTestlib) Process(inputs Inbounds, outputs Outbounds, params Params) (result *Result) {
 	inputs["checker"].SetMode(0744)
 
 	chk := utils.RandomString(10)
@@ -29,6 +34,12 @@ func (r CheckerTestlib) Process(inputs Inbounds, outputs Outbounds) (result *Res
 	inputs["input"].DupFile(inf, 0644)
 	inputs["output"].DupFile(ouf, 0644)
 	inputs["answer"].DupFile(asf, 0644)
+	// don't leave the answer in the working directory
+	defer func() {
+		for _, name := range []string{chk, inf, ouf, asf} {
+			os.Remove(name)
+		}
+	}()
 
 	res, err := judger.Judge(
 		judger.WithArgument(
@@ -49,3 +60,4 @@ func (r CheckerTestlib) Process(inputs Inbounds, outputs Outbounds) (result *Res
 }
 
 var _ Processor = CheckerTestlib{}
+var _ Versioner = CheckerTestlib{}
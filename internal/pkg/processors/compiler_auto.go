@@ -2,7 +2,9 @@ package processors
 
 import (
 	"errors"
-	"os/exec"
+	"os"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
@@ -18,7 +20,8 @@ var (
 
 // Compile source file in all language.
 //
-// Time limitation: 1min.
+// Time limitation: 1min. 编译器在私有目录中运行（见 [judger.WithIsolation]），
+// 只能读取工具链与源文件。
 //
 // 各语言默认参数如下：
 //
@@ -35,6 +38,8 @@ var (
 // Lcpp20: g++ [source] -o [result] --std=c++20
 //
 // Lpython, Lpython3: 用 cython 转化为 c 语言文件然后编译
+//
+// Params: "flags" 额外的编译参数（以空白分隔，python 除外），默认为空
 type CompilerAuto struct {
 	// input: source option
 	// output: result, log, judgerlog
@@ -44,7 +49,13 @@ func (r CompilerAuto) Label() (inputlabel []string, outputlabel []string) {
 	return []string{"source", "option"}, []string{"result", "log", "judgerlog"}
 }
 
-func (r CompilerAuto) Process(inputs Inbounds, outputs Outbounds) (result *Result) {
+// 编译参数或工具链变化时，版本随之变化
+func (r CompilerAuto) Version() string {
+	return "1; " + toolchainVersion("/usr/bin/gcc") + "; " +
+		toolchainVersion("/usr/bin/g++") + "; " + toolchainVersion("/usr/bin/cython")
+}
+
+func (r CompilerAuto) Process(inputs Inbounds, outputs Outbounds, params Params) (result *Result) {
 	var argv []string
 	// parse compile option
 	dat, err := inputs["option"].Get()
@@ -57,17 +68,24 @@ func (r CompilerAuto) Process(inputs Inbounds, outputs Outbounds) (result *Resul
 		return SysErrRes(err)
 	}
 
+	// 在私有目录中编译，使得提交的代码无法通过 #include 等方式读取评测机上的
+	// 其他文件（见 [judger.WithIsolation]）
+	box, err := makeBox()
+	if err != nil {
+		return SysErrRes(err)
+	}
+	defer os.RemoveAll(box)
 	basename := utils.RandomString(10)
 
 	switch conf.Lang {
 	case yutils.Lc:
-		inputs["source"].DupFile(basename+".c", 0644)
+		inputs["source"].DupFile(path.Join(box, basename+".c"), 0644)
 		argv = []string{
 			"/dev/null", "/dev/null", outputs["log"].Path(),
-			"/usr/bin/gcc", basename + ".c", "-o", outputs["result"].Path(),
+			"/usr/bin/gcc", basename + ".c", "-o", basename,
 		}
 	case yutils.Lcpp, yutils.Lcpp11, yutils.Lcpp14, yutils.Lcpp17, yutils.Lcpp20:
-		inputs["source"].DupFile(basename+".cpp", 0644)
+		inputs["source"].DupFile(path.Join(box, basename+".cpp"), 0644)
 		// detect c++ version
 		verArg := ""
 		switch conf.Lang {
@@ -85,7 +103,7 @@ func (r CompilerAuto) Process(inputs Inbounds, outputs Outbounds) (result *Resul
 
 		args := []string{
 			"/dev/null", "/dev/null", outputs["log"].Path(),
-			"/usr/bin/g++", basename + ".cpp", "-o", outputs["result"].Path(),
+			"/usr/bin/g++", basename + ".cpp", "-o", basename,
 		}
 		if verArg != "" {
 			args = append(args, verArg)
@@ -93,19 +111,14 @@ func (r CompilerAuto) Process(inputs Inbounds, outputs Outbounds) (result *Resul
 		argv = args
 	case yutils.Lpython, yutils.Lpython3: // 目前只编译 python3
 		// logger.Printf("detect python source")
-		c_src := utils.RandomString(10) + ".c"
-		py_src := utils.RandomString(10) + ".py"
+		c_src := basename + ".c"
+		py_src := basename + ".py"
 		// compile source to c file
-		utils.CopyFile(inputs["source"].Path(), py_src)
-		res, err := judger.Judge(
-			judger.WithPolicy("builtin:free"),
-			judger.WithLog(outputs["judgerlog"].Path(), 0),
-			judger.WithRealTime(time.Minute),
-			judger.WithOutput(10*judger.MB),
+		inputs["source"].DupFile(path.Join(box, py_src), 0644)
+		res, err := compileIn(box, outputs,
 			// 名字里含有 '-' cython 会报错
-			judger.WithArgument("/dev/null", "/dev/null", outputs["log"].Path(),
-				"/usr/bin/cython", py_src, "--embed", "-3", "-o", c_src),
-		)
+			"/dev/null", "/dev/null", outputs["log"].Path(),
+			"/usr/bin/cython", py_src, "--embed", "-3", "-o", c_src)
 		if err != nil {
 			return SysErrRes(err)
 		}
@@ -121,36 +134,59 @@ func (r CompilerAuto) Process(inputs Inbounds, outputs Outbounds) (result *Resul
 		if err != nil {
 			return SysErrRes(err)
 		}
-		args := []string{"-Wall", "-Wextra", "-fpie", "-o", outputs["result"].Path(), c_src}
+		args := []string{"/dev/null", "/dev/null", "/dev/null",
+			"/usr/bin/gcc", "-Wall", "-Wextra", "-fpie", "-o", basename, c_src}
 		args = append(args, CFLAGS...)
 		args = append(args, LDFLAGS...)
-
-		err = exec.Command("/usr/bin/gcc", args...).Run()
-		if err != nil {
-			return RtErrRes(err)
+		if res, err = compileIn(box, outputs, args...); err != nil {
+			return SysErrRes(err)
 		}
-		return &processor.Result{
-			Code: processor.Ok,
-			Msg:  "",
+		if res.Code != processor.Ok {
+			return res.ProcResult()
 		}
+		return collectResult(box, basename, outputs)
 	default:
 		return SysErrRes(ErrUnknownLang)
 	}
 
 	// compile other language
 	argv = append(argv, conf.ExtraArgs...)
-	res, err := judger.Judge(
+	argv = append(argv, strings.Fields(params.String("flags", ""))...)
+	res, err := compileIn(box, outputs, argv...)
+	if err != nil {
+		return SysErrRes(err)
+	}
+	if res.Code != processor.Ok {
+		return res.ProcResult()
+	}
+	return collectResult(box, basename, outputs)
+}
+
+// 在私有目录 box 中运行编译器，argv 同 [judger.WithArgument]
+func compileIn(box string, outputs Outbounds, argv ...string) (*judger.Result, error) {
+	return judger.Judge(
 		judger.WithArgument(argv...),
 		judger.WithJudger(judger.General),
 		judger.WithPolicy("builtin:free"),
 		judger.WithLog(outputs["judgerlog"].Path(), 0),
+		judger.WithIsolation(box),
+		// 临时文件也写在私有目录中
+		judger.WithEnviron(append(os.Environ(), "TMPDIR="+box)...),
 		judger.WithRealTime(time.Minute),
 		judger.WithOutput(10*judger.MB),
 	)
-	if err != nil {
+}
+
+// 将私有目录中的编译结果复制到 result
+func collectResult(box, name string, outputs Outbounds) *Result {
+	if err := collect(box, name, outputs["result"].Path()); err != nil {
 		return SysErrRes(err)
 	}
-	return res.ProcResult()
+	return &processor.Result{
+		Code: processor.Ok,
+		Msg:  "",
+	}
 }
 
 var _ Processor = CompilerAuto{}
+var _ Versioner = CompilerAuto{}
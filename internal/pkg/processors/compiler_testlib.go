@@ -1,7 +1,10 @@
 package processors
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	_ "embed"
@@ -15,6 +18,8 @@ var testlib []byte
 
 // Compile codeforces testlib source file using g++.
 // For input files, "source" represents source file.
+//
+// Params: "flags" 额外的编译参数（以空白分隔），默认为空
 type CompilerTestlib struct {
 	// input: source
 	// output: result, log, judgerlog
@@ -24,7 +29,12 @@ func (r CompilerTestlib) Label() (inputlabel []string, outputlabel []string) {
 	return []string{"source"}, []string{"result", "log", "judgerlog"}
 }
 
-func (r CompilerTestlib) Process(inputs Inbounds, outputs Outbounds) (result *Result) {
+// 编译参数、g++ 或 testlib.h 变化时，版本随之变化
+func (r CompilerTestlib) Version() string {
+	return fmt.Sprintf("1; %s; testlib %x", toolchainVersion("/usr/bin/g++"), sha256.Sum256(testlib))
+}
+
+func (r CompilerTestlib) Process(inputs Inbounds, outputs Outbounds, params Params) (result *Result) {
 	// create testlib.h
 	err := os.WriteFile("testlib.h", testlib, os.ModePerm)
 	if err != nil {
@@ -41,9 +51,11 @@ func (r CompilerTestlib) Process(inputs Inbounds, outputs Outbounds) (result *Re
 		return SysErrRes(err)
 	}
 	// compile
+	argv := []string{"/dev/null", "/dev/null", outputs["log"].Path(),
+		"/usr/bin/g++", src, "-o", outputs["result"].Path(), "-O2", "-Wall"}
+	argv = append(argv, strings.Fields(params.String("flags", ""))...)
 	res, err := judger.Judge(
-		judger.WithArgument("/dev/null", "/dev/null", outputs["log"].Path(),
-			"/usr/bin/g++", src, "-o", outputs["result"].Path(), "-O2", "-Wall"),
+		judger.WithArgument(argv...),
 		judger.WithJudger(judger.General),
 		judger.WithPolicy("builtin:free"),
 		judger.WithLog(outputs["judgerlog"].Path(), 0),
@@ -57,3 +69,4 @@ func (r CompilerTestlib) Process(inputs Inbounds, outputs Outbounds) (result *Re
 }
 
 var _ Processor = CompilerTestlib{}
+var _ Versioner = CompilerTestlib{}
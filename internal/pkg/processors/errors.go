@@ -0,0 +1,9 @@
+package processors
+
+import "github.com/super-yaoj/yaoj-core/pkg/yerrors"
+
+var (
+	ErrInvalidDefinition  = yerrors.New("invalid external processor definition")
+	ErrDuplicateProcessor = yerrors.New("processor already registered")
+	ErrInvalidFilename    = yerrors.New("invalid filename")
+)
@@ -0,0 +1,251 @@
+package processors
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// 外部处理器的定义文件的扩展名
+const ExternalExt = ".json"
+
+// 外部处理器的默认运行时间限制
+const ExternalTimeout = time.Minute
+
+// 外部处理器的内存限制
+const ExternalMemory = judger.GB
+
+// 外部处理器可以同时打开的文件数
+const ExternalFileno = 64
+
+// 外部处理器的定义文件（JSON）
+//
+// 例如
+//
+//	{
+//	  "name": "checker:exact",
+//	  "description": "compare output and answer byte by byte",
+//	  "input": [{"label": "output", "type": 0}, {"label": "answer", "type": 0}],
+//	  "output": [{"label": "report", "type": 0}],
+//	  "version": "1",
+//	  "command": ["./exact", "--strict"]
+//	}
+type ExternalDef struct {
+	processor.Meta
+	// 可执行文件及其参数，可执行文件的相对路径相对于定义文件所在的文件夹
+	Command []string `json:"command"`
+	// 运行时间限制（毫秒），0 表示 [ExternalTimeout]
+	Timeout int `json:"timeout"`
+}
+
+// 传给外部处理器的清单（以 JSON 的形式写入其标准输入）
+type ExternalManifest struct {
+	// 输入文件的绝对路径
+	Input map[string]string `json:"input"`
+	// 输出文件的绝对路径（在私有目录中），处理器需要将输出写入这些文件
+	Output map[string]string `json:"output"`
+	// 结点的静态参数
+	Params Params `json:"params"`
+}
+
+// 由独立的可执行文件实现的处理器
+//
+// 可执行文件由评测端的维护者提供，但是它处理的是提交的数据，因此与编译器一样
+// 在私有目录中运行（见 [judger.WithIsolation]）：除了工具链之外只能读取可执行
+// 文件所在的文件夹与结点的输入，输出先写入私有目录，结束后再复制出来，并且有
+// 时间、内存与文件数的限制。其标准输入为 [ExternalManifest] 的 JSON，标准输出应当为
+// processor.Result 的 JSON（其中 Code 为整数，缺省为 Ok）。版本由定义文件中的
+// 版本与可执行文件的哈希值组成，因此更新可执行文件会使缓存失效。
+type External struct {
+	def ExternalDef
+	// 可执行文件的绝对路径
+	exe string
+	// 可执行文件的哈希值
+	sum [sha256.Size]byte
+}
+
+func (r External) Label() (inputlabel []string, outputlabel []string) {
+	return r.def.Meta.Label()
+}
+
+func (r External) Description() string {
+	return r.def.Description
+}
+
+func (r External) ContentType() (input []utils.CtntType, output []utils.CtntType) {
+	for _, port := range r.def.Input {
+		input = append(input, port.Type)
+	}
+	for _, port := range r.def.Output {
+		output = append(output, port.Type)
+	}
+	return
+}
+
+func (r External) Version() string {
+	return fmt.Sprintf("%s; %x", r.def.Version, r.sum)
+}
+
+func (r External) Process(inputs Inbounds, outputs Outbounds, params Params) (result *Result) {
+	box, err := makeBox()
+	if err != nil {
+		return SysErrRes(err)
+	}
+	defer os.RemoveAll(box)
+
+	manifest := ExternalManifest{
+		Input:  map[string]string{},
+		Output: map[string]string{},
+		Params: params,
+	}
+	readonly := append([]string{filepath.Dir(r.exe)}, judger.DefaultReadOnly...)
+	for label, store := range inputs {
+		name, err := filepath.Abs(store.Path())
+		if err != nil {
+			return SysErrRes(err)
+		}
+		manifest.Input[label] = name
+		readonly = append(readonly, name)
+	}
+	// 私有目录中的输出文件名
+	names := map[string]string{}
+	for label := range outputs {
+		names[label] = utils.RandomString(10)
+		manifest.Output[label] = filepath.Join(box, names[label])
+	}
+	ctnt, err := json.Marshal(manifest)
+	if err != nil {
+		return SysErrRes(err)
+	}
+	inf, ouf, erf := utils.RandomString(10), utils.RandomString(10), utils.RandomString(10)
+	logf := utils.RandomString(10)
+	defer func() {
+		for _, name := range []string{inf, ouf, erf, logf} {
+			os.Remove(name)
+		}
+	}()
+	if err := os.WriteFile(inf, ctnt, 0644); err != nil {
+		return SysErrRes(err)
+	}
+
+	timeout := ExternalTimeout
+	if r.def.Timeout > 0 {
+		timeout = time.Duration(r.def.Timeout) * time.Millisecond
+	}
+	res, err := judger.Judge(
+		judger.WithArgument(append([]string{inf, ouf, erf, r.exe}, r.def.Command[1:]...)...),
+		judger.WithJudger(judger.General),
+		judger.WithPolicy("builtin:free"),
+		judger.WithLog(logf, 0),
+		judger.WithIsolation(box, readonly...),
+		judger.WithEnviron(append(os.Environ(), "TMPDIR="+box)...),
+		judger.WithRealTime(timeout),
+		judger.WithCpuTime(timeout),
+		judger.WithRealMemory(ExternalMemory),
+		judger.WithOutput(10*judger.MB),
+		judger.WithFileno(ExternalFileno),
+	)
+	if err != nil {
+		return SysErrRes(err)
+	}
+	if res.Code != processor.Ok {
+		stderr, _ := os.ReadFile(erf)
+		result = res.ProcResult()
+		result.Msg = strings.TrimSpace(result.Msg + "\n" + string(stderr))
+		return result
+	}
+
+	output, err := os.ReadFile(ouf)
+	if err != nil {
+		return SysErrRes(err)
+	}
+	result = &Result{}
+	if err := json.Unmarshal(output, result); err != nil {
+		return SysErrRes(yerrors.Situated("parse result", err))
+	}
+	for label, name := range names {
+		// 执行失败时可能只有部分输出
+		if err := collect(box, name, outputs[label].Path()); err != nil && result.Code == processor.Ok {
+			return SysErrRes(yerrors.Annotated("label", label, err))
+		}
+	}
+	return result
+}
+
+// 读取外部处理器的定义文件
+func LoadExternal(name string) (*External, error) {
+	ctnt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var def ExternalDef
+	if err := json.Unmarshal(ctnt, &def); err != nil {
+		return nil, yerrors.Annotated("file", name, yerrors.Situated("parse", err))
+	}
+	if def.Name == "" || len(def.Command) == 0 || def.Command[0] == "" {
+		return nil, yerrors.Annotated("file", name, ErrInvalidDefinition)
+	}
+	labels := map[string]bool{}
+	for _, port := range append(append([]processor.Port{}, def.Input...), def.Output...) {
+		if port.Label == "" || labels[port.Label] {
+			return nil, yerrors.Annotated("label", port.Label, yerrors.Annotated("file", name, ErrInvalidDefinition))
+		}
+		labels[port.Label] = true
+	}
+
+	exe := def.Command[0]
+	if !filepath.IsAbs(exe) {
+		exe = filepath.Join(filepath.Dir(name), exe)
+	}
+	exe, err = filepath.Abs(exe)
+	if err != nil {
+		return nil, err
+	}
+	bin, err := os.ReadFile(exe)
+	if err != nil {
+		return nil, yerrors.Annotated("file", name, err)
+	}
+	return &External{def: def, exe: exe, sum: sha256.Sum256(bin)}, nil
+}
+
+// 注册文件夹中（不递归）所有的外部处理器，见 [ExternalDef]
+//
+// 通常在启动评测端时调用。处理器不能与已经注册的处理器重名。
+func LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ExternalExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		proc, err := LoadExternal(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if Get(proc.def.Name) != nil {
+			return yerrors.Annotated("processor", proc.def.Name, ErrDuplicateProcessor)
+		}
+		Register(proc.def.Name, *proc)
+	}
+	return nil
+}
+
+var _ Processor = External{}
+var _ Versioner = External{}
+var _ processor.Describer = External{}
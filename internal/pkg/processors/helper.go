@@ -1,13 +1,19 @@
 package processors
 
 import (
+	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 // `s` contains a series of number seperated by space, denoting
@@ -40,6 +46,42 @@ func runLimOptions(s data.RunConf) []judger.OptionProvider {
 	return options
 }
 
+// 在当前目录下创建私有目录（见 [judger.WithIsolation]），返回其绝对路径
+func makeBox() (string, error) {
+	box, err := os.MkdirTemp(".", "box-")
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(box)
+	if err == nil {
+		err = os.Chmod(abs, 0755)
+	}
+	if err != nil {
+		os.RemoveAll(box)
+		return "", err
+	}
+	return abs, nil
+}
+
+// 将程序在私有目录 box 中写入的文件 name 复制到 dst
+//
+// 文件可能被程序替换为符号链接，因此解析后的路径必须仍然在 box 中
+func collect(box string, name string, dst string) error {
+	src, err := filepath.EvalSymlinks(path.Join(box, name))
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(box)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(src, root+"/") {
+		return yerrors.Annotated("filename", name, ErrInvalidFilename)
+	}
+	_, err = utils.CopyFile(src, dst)
+	return err
+}
+
 func RtErrRes(err error) *Result {
 	return &Result{
 		Code: processor.RuntimeError,
@@ -85,3 +127,21 @@ func PythonFlags() (cflags []string, ldflags []string, err error) {
 
 	return
 }
+
+var toolchainVersions sync.Map
+
+// 工具链的版本信息（`name --version` 输出的第一行），结果会被缓存
+//
+// 工具链不存在时返回 "none"
+func toolchainVersion(name string) string {
+	if ver, ok := toolchainVersions.Load(name); ok {
+		return ver.(string)
+	}
+	ver := "none"
+	out, err := exec.Command(name, "--version").Output()
+	if err == nil {
+		ver = strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	}
+	toolchainVersions.Store(name, ver)
+	return ver
+}
@@ -1,5 +1,7 @@
 package processors
 
+import "github.com/super-yaoj/yaoj-core/pkg/processor"
+
 var processors map[string]Processor = make(map[string]Processor)
 
 func Get(name string) Processor {
@@ -11,8 +13,11 @@ func GetAll() map[string]Processor {
 }
 
 // register a processor to system
+//
+// 同时注册到 [processor.Register]，从而可以通过 processor.All 得到其元信息
 func Register(name string, proc Processor) {
 	processors[name] = proc
+	processor.Register(name, proc)
 }
 
 func init() {
@@ -7,7 +7,9 @@ import (
 
 type (
 	Processor = processor.Processor
+	Versioner = processor.Versioner
 	Result    = processor.Result
 	Inbounds  = processor.Inbounds
 	Outbounds = processor.Outbounds
+	Params    = processor.Params
 )
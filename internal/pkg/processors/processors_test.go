@@ -2,26 +2,20 @@ package processors_test
 
 import (
 	"os"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/k0kubun/pp/v3"
+	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
 	"github.com/super-yaoj/yaoj-core/internal/pkg/processors"
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/processor"
 	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 	yutils "github.com/super-yaoj/yaoj-utils"
 )
 
-// go:generate go build -buildmode=plugin -o ./testdata/diff-go ./testdata/diff-go/main.go
-// func TestLoad(t *testing.T) {
-// 	proc, err := processor.LoadPlugin("testdata/diff-go/main.so")
-// 	if err != nil {
-// 		t.Error(err)
-// 	}
-//
-// 	t.Log(proc.Label())
-// }
-
 var c_src = `
 #include<stdio.h>
 int main() {
@@ -128,7 +122,7 @@ func TestProcessors(t *testing.T) {
 					"log":       data.NewFile("main.log", nil),
 					"judgerlog": data.NewFile("runtime.log", nil),
 				}
-				res := processors.CompilerAuto{}.Process(inputs, outputs)
+				res := processors.CompilerAuto{}.Process(inputs, outputs, nil)
 				if res.Code != processor.Ok {
 					data_log, _ := outputs["log"].Get()
 					t.Logf("log: %s", string(data_log))
@@ -147,6 +141,11 @@ func TestProcessors(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		// file IO in nested directories
+		err = os.WriteFile("exec_nested", []byte("#!/bin/sh\ncat data/a.in > out/a.out\n"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		var testcases = []struct {
 			name  string
@@ -168,6 +167,11 @@ func TestProcessors(t *testing.T) {
 			{"stdio", "exec_cpp", "exec.in", data.RunConf{
 				RealTime: 5 * 1000,
 			}},
+			{"nested", "exec_nested", "exec.in", data.RunConf{
+				RealTime: 5 * 1000,
+				Inf:      "data/a.in",
+				Ouf:      "out/a.out",
+			}},
 		}
 
 		for _, testcase := range testcases {
@@ -182,7 +186,7 @@ func TestProcessors(t *testing.T) {
 					"stderr":    data.NewFile("exec.err", nil),
 					"judgerlog": data.NewFile("runtime.log", nil),
 				}
-				res := processors.RunnerAuto{}.Process(inputs, outputs)
+				res := processors.RunnerAuto{}.Process(inputs, outputs, nil)
 				if res.Code != processor.Ok {
 					data_runtime, _ := outputs["judgerlog"].Get()
 					t.Logf("runtime.log: %s", string(data_runtime))
@@ -191,8 +195,27 @@ func TestProcessors(t *testing.T) {
 				}
 				data_stdout, _ := outputs["stdout"].Get()
 				t.Log("stdout:", string(data_stdout))
+				if testcase.name == "nested" && string(data_stdout) != "1 2" {
+					t.Fatal("invalid output", string(data_stdout))
+				}
 			})
 		}
+
+		// file IO must stay in the private directory
+		conf := data.RunConf{RealTime: 5 * 1000, Inf: "../a.in", Ouf: "a.out"}
+		inputs := processor.Inbounds{
+			"executable": data.NewFileFile("exec_c"),
+			"stdin":      data.NewFileFile("exec.in"),
+			"conf":       data.NewFile("tmp", conf.Serialize()),
+		}
+		outputs := processor.Outbounds{
+			"stdout":    data.NewFile("exec.out", nil),
+			"stderr":    data.NewFile("exec.err", nil),
+			"judgerlog": data.NewFile("runtime.log", nil),
+		}
+		if res := (processors.RunnerAuto{}).Process(inputs, outputs, nil); res.Code != processor.RuntimeError {
+			t.Fatal("expect runtime error", pp.Sprint(res))
+		}
 	})
 	t.Run("CompilerTestlib", func(t *testing.T) {
 		inputs := processor.Inbounds{
@@ -204,7 +227,7 @@ func TestProcessors(t *testing.T) {
 			"judgerlog": data.NewFile("runtime.log", nil),
 		}
 
-		res := processors.CompilerTestlib{}.Process(inputs, outputs)
+		res := processors.CompilerTestlib{}.Process(inputs, outputs, nil)
 		if res.Code != processor.Ok {
 			t.Fatalf("expect %v, found %v Msg=%s", processor.Ok, res.Code, res.Msg)
 		}
@@ -222,7 +245,7 @@ func TestProcessors(t *testing.T) {
 			"stderr":    data.NewFile("checker.err", nil),
 			"judgerlog": data.NewFile("runtime.log", nil),
 		}
-		res := processors.CheckerTestlib{}.Process(inputs, outputs)
+		res := processors.CheckerTestlib{}.Process(inputs, outputs, nil)
 		if res.Code != processor.Ok {
 			t.Fatalf("expect %v, found %v Msg=%s", processor.Ok, res.Code, res.Msg)
 		}
@@ -235,5 +258,148 @@ func TestManager(t *testing.T) {
 	for k := range mp {
 		input, output := processors.Get(k).Label()
 		t.Log(k, input, output)
+		meta, ok := processor.Lookup(k)
+		if !ok || processor.Get(k) == nil {
+			t.Fatal("processor not registered", k)
+		}
+		if strings.Join(processor.InputLabel(k), ",") != strings.Join(input, ",") ||
+			strings.Join(processor.OutputLabel(k), ",") != strings.Join(output, ",") {
+			t.Fatal("invalid labels", k, meta)
+		}
+		if meta.Version == "" || meta.Description == "" {
+			t.Fatal("invalid meta", meta)
+		}
+	}
+}
+
+// 将输入 in 复制到输出 out 的外部处理器
+var copyScript = `#!/bin/sh
+m=$(cat)
+in=$(echo "$m" | sed 's/.*"input":{"in":"\([^"]*\)".*/\1/')
+out=$(echo "$m" | sed 's/.*"output":{"out":"\([^"]*\)".*/\1/')
+cp "$in" "$out"
+echo '{"Code":0,"Msg":"copied"}'
+`
+
+func TestExternal(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(path.Join(dir, "copy.sh"), []byte(copyScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	def := `{
+		"name": "test:copy",
+		"description": "copy input to output",
+		"input": [{"label": "in", "type": 0}],
+		"output": [{"label": "out", "type": 0}],
+		"version": "1",
+		"command": ["copy.sh"]
+	}`
+	if err := os.WriteFile(path.Join(dir, "copy.json"), []byte(def), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := processors.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	meta, ok := processor.Lookup("test:copy")
+	if !ok || meta.Description != "copy input to output" || !strings.HasPrefix(meta.Version, "1; ") {
+		t.Fatal("invalid meta", meta)
+	}
+	if err := processors.LoadDir(dir); !yerrors.Is(err, processors.ErrDuplicateProcessor) {
+		t.Fatal("invalid error", err)
+	}
+
+	work := t.TempDir()
+	chdir(t, work)
+	inputs := processors.Inbounds{"in": data.NewFile(path.Join(work, "in"), []byte("hello"))}
+	outputs := processors.Outbounds{"out": data.NewFile(path.Join(work, "out"), nil)}
+	res := processors.Get("test:copy").Process(inputs, outputs, nil)
+	if res.Code != processor.Ok || res.Msg != "copied" {
+		t.Fatal("invalid result", res)
+	}
+	if ctnt, _ := outputs["out"].Get(); string(ctnt) != "hello" {
+		t.Fatal("invalid output", string(ctnt))
+	}
+	// temporary files are removed
+	if entries, _ := os.ReadDir(work); len(entries) != 2 {
+		t.Fatal("temporary files left", entries)
+	}
+
+	// 在沙箱中只能读取声明的输入
+	if os.Geteuid() == 0 {
+		old := judger.SetBackend(judger.NewSandbox(os.Getenv("YAOJ_TEST_CGROUP")))
+		defer judger.SetBackend(old)
+		res := processors.Get("test:copy").Process(inputs, outputs, nil)
+		if res.Code != processor.Ok {
+			t.Fatal("invalid result in sandbox", res)
+		}
+		secret := data.NewFile(path.Join(work, "secret"), []byte("secret"))
+		peek := strings.Replace(copyScript, `cp "$in" "$out"`, `cp `+secret.Path()+` "$out" || exit 1`, 1)
+		if err := os.WriteFile(path.Join(dir, "copy.sh"), []byte(peek), 0755); err != nil {
+			t.Fatal(err)
+		}
+		peeker, err := processors.LoadExternal(path.Join(dir, "copy.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := peeker.Process(inputs, outputs, nil); res.Code == processor.Ok {
+			t.Fatal("undeclared file read", res)
+		}
+	}
+
+	bad := t.TempDir()
+	os.WriteFile(path.Join(bad, "bad.json"), []byte(`{"name": "test:bad"}`), 0644)
+	if err := processors.LoadDir(bad); !yerrors.Is(err, processors.ErrInvalidDefinition) {
+		t.Fatal("invalid error", err)
+	}
+}
+
+// 在 dir 中运行测试，结束后切换回原来的工作目录
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestCompilerSandbox(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root required")
+	}
+	old := judger.SetBackend(judger.NewSandbox(os.Getenv("YAOJ_TEST_CGROUP")))
+	defer judger.SetBackend(old)
+	chdir(t, t.TempDir())
+
+	compile := func(src string) *processor.Result {
+		inputs := processor.Inbounds{
+			"source": data.NewFile("main.cpp", []byte(src)),
+			"option": data.NewFile("option", (&data.CompileConf{Lang: yutils.Lcpp}).Serialize()),
+		}
+		outputs := processor.Outbounds{
+			"result":    data.NewFile("main", nil),
+			"log":       data.NewFile("main.log", nil),
+			"judgerlog": data.NewFile("judger.log", nil),
+		}
+		res := processors.CompilerAuto{}.Process(inputs, outputs, nil)
+		log, _ := outputs["log"].Get()
+		t.Logf("%v: %s", res, log)
+		return res
+	}
+	if res := compile(cpp_src); res.Code != processor.Ok {
+		t.Fatal("invalid result", res)
+	}
+	if entries, _ := os.ReadDir("."); len(entries) != 5 {
+		t.Fatal("temporary files left", entries)
+	}
+	// 不能读取评测机上的其他文件
+	if res := compile("#include \"/etc/shadow\"\n" + cpp_src); res.Code == processor.Ok {
+		t.Fatal("compiler reads files outside the box")
 	}
 }
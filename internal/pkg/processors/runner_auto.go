@@ -1,12 +1,23 @@
 package processors
 
 import (
+	"os"
+	"path"
+	"strings"
+
 	"github.com/super-yaoj/yaoj-core/internal/pkg/judger"
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 // Run a program automatically.
+//
+// The program runs in a private directory (see [judger.WithIsolation]), which
+// only contains the executable and the input file (for file IO). Only with the
+// sandbox backend (see [judger.Isolates]) is it really isolated, i.e. cannot
+// access files of other nodes or the network. Other backends only run it in
+// the directory, relying on the policy "builtin:yaoj" (cgo backend) if any.
 type RunnerAuto struct {
 	// input: executable, stdin, conf
 	// output: stdout, stderr, judgerlog
@@ -16,9 +27,20 @@ func (r RunnerAuto) Label() (inputlabel []string, outputlabel []string) {
 	return []string{"executable", "stdin", "conf"}, []string{"stdout", "stderr", "judgerlog"}
 }
 
-func (r RunnerAuto) Process(inputs Inbounds, outputs Outbounds) *Result {
-	// make it executable
-	inputs["executable"].SetMode(0744)
+func (r RunnerAuto) Version() string {
+	return "2"
+}
+
+// path of a file in the private directory, name must be relative and inside it
+func boxPath(box string, name string) (string, error) {
+	clean := path.Clean(name)
+	if name == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", yerrors.Annotated("filename", name, ErrInvalidFilename)
+	}
+	return path.Join(box, clean), nil
+}
+
+func (r RunnerAuto) Process(inputs Inbounds, outputs Outbounds, params Params) *Result {
 	// to file
 	_, err := outputs["stdout"].File()
 	if err != nil {
@@ -43,24 +65,54 @@ func (r RunnerAuto) Process(inputs Inbounds, outputs Outbounds) *Result {
 		return RtErrRes(err)
 	}
 
+	// private directory
+	box, err := makeBox()
+	if err != nil {
+		return SysErrRes(err)
+	}
+	defer os.RemoveAll(box)
+	exe := path.Join(box, "main")
+	if err := inputs["executable"].DupFile(exe, 0755); err != nil {
+		return SysErrRes(err)
+	}
+
 	options := []judger.OptionProvider{
 		judger.WithJudger(judger.General),
 		judger.WithPolicy("builtin:yaoj"),
 		judger.WithLog(outputs["judgerlog"].Path(), 0),
+		judger.WithIsolation(box),
 	}
 
+	var inf, ouf string
 	if conf.IsFileIO() {
-		if _, err := utils.CopyFile(inputs["stdin"].Path(), conf.Inf); err != nil {
+		if inf, err = boxPath(box, conf.Inf); err != nil {
+			return RtErrRes(err)
+		}
+		if ouf, err = boxPath(box, conf.Ouf); err != nil {
+			return RtErrRes(err)
+		}
+		// 文件名可以包含文件夹，例如 "data/a.in"
+		if err := os.MkdirAll(path.Dir(inf), 0755); err != nil {
+			return SysErrRes(err)
+		}
+		if err := os.MkdirAll(path.Dir(ouf), 0755); err != nil {
+			return SysErrRes(err)
+		}
+		// 程序（可能以其他用户运行）需要在其中创建输出文件
+		if err := os.Chmod(path.Dir(ouf), 0777); err != nil {
+			return SysErrRes(err)
+		}
+		if _, err := utils.CopyFile(inputs["stdin"].Path(), inf); err != nil {
 			return RtErrRes(err)
 		}
 		options = append(options, judger.WithArgument("/dev/null", "/dev/null",
-			outputs["stderr"].Path(), inputs["executable"].Path()))
+			outputs["stderr"].Path(), exe))
 	} else { // stdio
 		options = append(options, judger.WithArgument(
 			inputs["stdin"].Path(),
 			outputs["stdout"].Path(),
 			outputs["stderr"].Path(),
-			inputs["executable"].Path(),
+			exe,
 		))
 	}
 
@@ -71,10 +123,12 @@ func (r RunnerAuto) Process(inputs Inbounds, outputs Outbounds) *Result {
 		return SysErrRes(err)
 	}
 
+	// collect output, which may be missing
 	if conf.IsFileIO() {
-		utils.CopyFile(conf.Ouf, outputs["stdout"].Path())
+		collect(box, path.Clean(conf.Ouf), outputs["stdout"].Path())
 	}
 	return res.ProcResult()
 }
 
 var _ Processor = RunnerAuto{}
+var _ Versioner = RunnerAuto{}
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/super-yaoj/yaoj-core/pkg/problem"
+)
+
+// Service 的配置
+type Option struct {
+	// 全局缓存的容量上限（byte），0 表示不限制
+	CacheBudget int64
+	// 远程缓存服务的地址，为空表示不使用
+	RemoteCache string
+	// 远程缓存每个请求的超时时间，0 表示使用默认值
+	RemoteCacheTimeout time.Duration
+	// 与远程缓存服务共享的 token
+	RemoteCacheToken string
+	// 加载题目压缩包时的限制
+	ExtractLimits problem.ExtractLimits
+}
+
+type OptionProvider func(*Option)
+
+// 设置全局缓存的容量上限（byte），0 表示不限制
+func WithCacheBudget(budget int64) OptionProvider {
+	return func(o *Option) {
+		o.CacheBudget = budget
+	}
+}
+
+// 使用远程缓存服务（见 workflowruntime.RemoteCache），其优先级低于本地的全局缓存
+func WithRemoteCache(addr string) OptionProvider {
+	return func(o *Option) {
+		o.RemoteCache = addr
+	}
+}
+
+// 设置远程缓存每个请求的超时时间，默认为 workflowruntime.DefaultRemoteTimeout
+func WithRemoteCacheTimeout(timeout time.Duration) OptionProvider {
+	return func(o *Option) {
+		o.RemoteCacheTimeout = timeout
+	}
+}
+
+// 设置与远程缓存服务共享的 token
+func WithRemoteCacheToken(token string) OptionProvider {
+	return func(o *Option) {
+		o.RemoteCacheToken = token
+	}
+}
+
+// 设置加载题目压缩包时的限制，默认为 problem.DefaultExtractLimits
+func WithExtractLimits(limits problem.ExtractLimits) OptionProvider {
+	return func(o *Option) {
+		o.ExtractLimits = limits
+	}
+}
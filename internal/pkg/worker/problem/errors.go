@@ -5,4 +5,6 @@ import "github.com/super-yaoj/yaoj-core/pkg/yerrors"
 var (
 	ErrInvalidSet      = yerrors.New("invalid test set")
 	ErrUnknownAnalyzer = yerrors.New("unknown analyzer")
+	ErrNotHackable     = yerrors.New("problem not hackable")
+	ErrInvalidHack     = yerrors.New("invalid hack data")
 )
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
 
 	"github.com/super-yaoj/yaoj-core/internal/pkg/analyzers"
 	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/log"
 	"github.com/super-yaoj/yaoj-core/pkg/problem"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow"
@@ -22,8 +24,15 @@ type RtProblem struct {
 	lg *log.Entry
 	// test
 	tot_dir int
-	// global cache
-	cache workflowruntime.RtNodeCache
+	// 评测时使用的缓存（按优先级排列）
+	caches []workflowruntime.RtNodeCache
+	// 评测时结点输出存入的文件池，可以为 nil
+	pool *data.BlobStore
+}
+
+// 设置评测时使用的缓存，通常为 Service 的全局缓存
+func (r *RtProblem) UseCache(cachers ...workflowruntime.RtNodeCache) {
+	r.caches = cachers
 }
 
 // 创建一个新的临时文件夹用于数据组的评测
@@ -49,6 +58,10 @@ func (r *RtProblem) TestsetDir() (string, error) {
 	return dir, nil
 }
 
+// 评测一个数据组
+//
+// 数据组的 Record 作为 workflow 的 Gtestset 域，子任务的 Record 作为评测其中的
+// 测试点时的 Gsubtask 域（没有子任务时为空）。
 func (r *RtProblem) RunTestset(set *problem.TestdataGroup, subm problem.Submission) (*problem.Result, error) {
 	// check test set
 	if r.Extra != set && r.Pretest != set && r.Data.Data != set {
@@ -60,12 +73,18 @@ func (r *RtProblem) RunTestset(set *problem.TestdataGroup, subm problem.Submissi
 		return nil, err
 	}
 	inbounds := subm.Download(path.Join(testdir, "subm"))
-	inbounds[workflow.Gstatic] = r.Static.InboundGroup()
+	if inbounds[workflow.Gstatic], err = r.Static.InboundGroup(); err != nil {
+		return nil, err
+	}
+	if inbounds[workflow.Gtestset], err = set.Record.InboundGroup(); err != nil {
+		return nil, err
+	}
 
 	result := &problem.Result{}
 
 	grader := NewGrader(set.Method, set.Fullscore, len(set.Testcases))
 	if set.Testcases != nil {
+		inbounds[workflow.Gsubtask] = workflow.InboundGroup{}
 		res, err := r.RunTestcases(set.Testcases, inbounds, workdir, grader)
 		if err != nil {
 			return nil, err
@@ -74,6 +93,9 @@ func (r *RtProblem) RunTestset(set *problem.TestdataGroup, subm problem.Submissi
 	} else {
 		for id, subtask := range set.Subtasks {
 			sub_grader := NewGrader(subtask.Method, subtask.Fullscore, len(subtask.Testcases))
+			if inbounds[workflow.Gsubtask], err = subtask.Record.InboundGroup(); err != nil {
+				return nil, err
+			}
 			sub_res, err := r.RunTestcases(subtask.Testcases, inbounds, workdir, sub_grader)
 			if err != nil {
 				return nil, err
@@ -92,6 +114,9 @@ func (r *RtProblem) RunTestset(set *problem.TestdataGroup, subm problem.Submissi
 	return result, nil
 }
 
+// 依次评测测试点
+//
+// 评测每个测试点前会合并各层的运行配置，见 [RunConfField]。
 func (r *RtProblem) RunTestcases(testcases []*problem.TestcaseData,
 	inbounds workflow.InboundGroups, workdir string, grader *Grader) ([]workflow.Result, error) {
 	// testcase fullscore
@@ -107,17 +132,26 @@ func (r *RtProblem) RunTestcases(testcases []*problem.TestcaseData,
 				},
 			})
 		} else {
-			inbounds[workflow.Gtests] = testcase.InboundGroup()
+			tests, err := testcase.InboundGroup()
+			if err != nil {
+				return nil, err
+			}
+			inbounds[workflow.Gtests] = tests
 			analyzer := analyzers.Get(r.AnalyzerName)
 			if analyzer == nil {
 				return nil, yerrors.Annotated("analyzer", r.AnalyzerName, ErrUnknownAnalyzer)
 			}
+			merged, err := mergeRunConf(inbounds, workdir)
+			if err != nil {
+				return nil, err
+			}
 			wk, err := workflowruntime.New(r.Workflow, workdir, fullscore, analyzer, r.lg)
 			if err != nil {
 				return nil, err
 			}
-			wk.UseCache(r.cache)
-			test_res, err := wk.Run(inbounds, false)
+			wk.UseCache(r.caches...)
+			wk.UsePool(r.pool)
+			test_res, err := wk.Run(merged, false)
 			if err != nil {
 				return nil, err
 			}
@@ -128,6 +162,163 @@ func (r *RtProblem) RunTestcases(testcases []*problem.TestcaseData,
 	return results, nil
 }
 
+// 运行配置所在的字段
+//
+// 题目（Gstatic）、数据组（Gtestset）、子任务（Gsubtask）与测试点（Gtests）的
+// 数据中都可以有该字段，优先级依次升高。高层的配置可以只包含部分字段，见
+// [data.MergeRunConf]。
+const RunConfField = "runner_config"
+
+// 运行配置的各层，优先级从低到高
+var runConfLayers = []workflow.Groupname{workflow.Gstatic, workflow.Gtestset, workflow.Gsubtask, workflow.Gtests}
+
+// 合并各层的运行配置
+//
+// 如果 Gstatic 以外的层设置了运行配置，那么合并后的配置写入 dir 中，并替换所有
+// 层的 [RunConfField] 字段，因此 workflow 无论从哪一层读取都会得到合并后的配置。
+// 返回新的读入数据，inbounds 本身不变。
+func mergeRunConf(inbounds workflow.InboundGroups, dir string) (workflow.InboundGroups, error) {
+	overridden := false
+	var layers [][]byte
+	for _, gname := range runConfLayers {
+		store, ok := inbounds[gname][RunConfField]
+		if !ok {
+			continue
+		}
+		ctnt, err := store.Get()
+		if err != nil {
+			return nil, yerrors.Annotated("group", gname, err)
+		}
+		layers = append(layers, ctnt)
+		overridden = overridden || gname != workflow.Gstatic
+	}
+	if !overridden {
+		return inbounds, nil
+	}
+	conf, err := data.MergeRunConf(layers...)
+	if err != nil {
+		return nil, yerrors.Situated("merge "+RunConfField, err)
+	}
+	store := data.NewFile(path.Join(dir, "_"+RunConfField), conf.Serialize())
+
+	res := workflow.InboundGroups{}
+	for gname, group := range inbounds {
+		res[gname] = group
+	}
+	for _, gname := range runConfLayers {
+		group := workflow.InboundGroup{}
+		for field, store := range inbounds[gname] {
+			group[field] = store
+		}
+		group[RunConfField] = store
+		res[gname] = group
+	}
+	return res, nil
+}
+
+// 评测一次 hack
+//
+// std 为标准答案，target 为被 hack 的提交，hack 的 Gtests 域包含 hack 时提交的
+// 字段（见 HackFields）。
+//
+// 首先用 std 执行不完整的数据，再通过 HackIOMap 将中间输出文件填补到 Gtests
+// 中，最后用填补后的数据评测 target。这些中间输出所依赖的结点都必须正常执行
+// （结果为 Ok），否则返回 ErrInvalidHack。
+//
+// hack 数据不属于任何子任务和数据组，因此 Gsubtask 与 Gtestset 域为空，可以
+// hack 的题目的 workflow 不能依赖这两个域（见 problem.Data.Validate）。
+func (r *RtProblem) RunHack(std, target, hack problem.Submission) (*problem.HackResult, error) {
+	if !r.Hackable() {
+		return nil, ErrNotHackable
+	}
+	// check hack data
+	for field, limit := range r.HackFields {
+		store := hack[workflow.Gtests][field]
+		if store == nil {
+			return nil, yerrors.Annotated("field", field, ErrInvalidHack)
+		}
+		ctnt, err := store.Get()
+		if err != nil {
+			return nil, err
+		}
+		if err := limit.Validate(ctnt); err != nil {
+			return nil, yerrors.Annotated("field", field, yerrors.Annotated("reason", err, ErrInvalidHack))
+		}
+	}
+	analyzer := analyzers.Get(r.AnalyzerName)
+	if analyzer == nil {
+		return nil, yerrors.Annotated("analyzer", r.AnalyzerName, ErrUnknownAnalyzer)
+	}
+
+	testdir, err := r.TestsetDir()
+	if err != nil {
+		return nil, err
+	}
+	workdir := path.Join(testdir, "work")
+	subdir := path.Join(testdir, "subm")
+	static, err := r.Static.InboundGroup()
+	if err != nil {
+		return nil, err
+	}
+	tests := hack.Download(subdir)[workflow.Gtests]
+	if tests == nil {
+		tests = workflow.InboundGroup{}
+	}
+
+	// run std with incomplete tests
+	inbounds := std.Download(subdir)
+	inbounds[workflow.Gstatic] = static
+	inbounds[workflow.Gtests] = workflow.InboundGroup{}
+	for field, store := range tests {
+		inbounds[workflow.Gtests][field] = store
+	}
+	hacker := analyzers.NewHack(r.HackIOMap)
+	wk, err := workflowruntime.New(r.Workflow, workdir, r.Fullscore, hacker, r.lg)
+	if err != nil {
+		return nil, err
+	}
+	wk.UseCache(r.caches...)
+	wk.UsePool(r.pool)
+	if _, err := wk.Run(inbounds, true); err != nil {
+		return nil, yerrors.Situated("run std", err)
+	}
+	// std 异常时其输出（例如超时前的部分输出）不能作为答案
+	if failed := hacker.Failed(); len(failed) > 0 {
+		names := make([]string, 0, len(failed))
+		for name := range failed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, yerrors.Annotated("result", failed[names[0]],
+			yerrors.Annotated("node", names[0], yerrors.Situated("run std", ErrInvalidHack)))
+	}
+	for field, store := range hacker.Data() {
+		if store == nil {
+			return nil, yerrors.Annotated("field", field, yerrors.Situated("std output", ErrInvalidHack))
+		}
+		tests[field] = store
+	}
+
+	// run target with complete tests
+	inbounds = target.Download(subdir)
+	inbounds[workflow.Gstatic] = static
+	inbounds[workflow.Gtests] = tests
+	wk, err = workflowruntime.New(r.Workflow, workdir, r.Fullscore, analyzer, r.lg)
+	if err != nil {
+		return nil, err
+	}
+	wk.UseCache(r.caches...)
+	wk.UsePool(r.pool)
+	res, err := wk.Run(inbounds, false)
+	if err != nil {
+		return nil, yerrors.Situated("run target", err)
+	}
+	return &problem.HackResult{
+		Success: res.Score < res.Fullscore,
+		Result:  *res,
+	}, nil
+}
+
 // 删除所有文件（销毁自身）
 func (r *RtProblem) Finalize() error {
 	err := os.RemoveAll(r.dir)
@@ -137,20 +328,33 @@ func (r *RtProblem) Finalize() error {
 	return err
 }
 
+// 设置评测时结点输出存入的文件池
+func (r *RtProblem) UsePool(pool *data.BlobStore) {
+	r.pool = pool
+}
+
+// 检查题目数据，在 problem.Data.Validate 的基础上检查分析器是否已经注册
+func Validate(data *problem.Data) problem.Diagnostics {
+	res := data.Validate()
+	if data.AnalyzerName != "" && analyzers.Get(data.AnalyzerName) == nil {
+		res = append(res, problem.Diagnostic{
+			Severity: problem.Error,
+			Path:     "analyzer",
+			Msg:      fmt.Sprintf("unknown analyzer %q", data.AnalyzerName),
+		})
+	}
+	return res
+}
+
 // create dir if necessary
 func New(data *problem.Data, dir string, logger *log.Entry) (*RtProblem, error) {
 	err := os.MkdirAll(dir, 0750)
 	if err != nil {
 		return nil, err
 	}
-	gcache, err := workflowruntime.NewCache(path.Join(dir, "cache"))
-	if err != nil {
-		return nil, err
-	}
 	return &RtProblem{
-		Data:  data,
-		dir:   dir,
-		lg:    logger.WithField("problem", dir),
-		cache: gcache,
+		Data: data,
+		dir:  dir,
+		lg:   logger.WithField("problem", dir),
 	}, nil
 }
@@ -1,13 +1,40 @@
 package problemruntime_test
 
 import (
+	"strings"
 	"testing"
 
 	problemruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/problem"
+	workflowruntime "github.com/super-yaoj/yaoj-core/internal/pkg/worker/workflow"
 	"github.com/super-yaoj/yaoj-core/internal/tests"
 	"github.com/super-yaoj/yaoj-core/pkg/log"
+	"github.com/super-yaoj/yaoj-core/pkg/problem"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow/preset"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
+// 输出部分答案后超时的 std
+var stdTLE = `
+#include <cstdio>
+int main() {
+	puts("1");
+	fflush(stdout);
+	for (volatile int i = 0; ; i++);
+}
+`
+
+// 输出部分答案后运行错误的 std
+var stdRE = `
+#include <cstdio>
+#include <cstdlib>
+int main() {
+	puts("1");
+	fflush(stdout);
+	abort();
+}
+`
+
 func TestRtProblem(t *testing.T) {
 	lg := log.NewTest()
 	prob, err := tests.CreateProblem(t.TempDir(), lg)
@@ -24,6 +51,11 @@ func TestRtProblem(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	cache, err := workflowruntime.NewCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtprob.UseCache(cache)
 	res, err := rtprob.RunTestset(rtprob.Pretest, submission)
 	if err != nil {
 		t.Fatal(err)
@@ -39,6 +71,121 @@ func TestRtProblem(t *testing.T) {
 		t.Fatal("invalid result", res)
 	}
 
+	// hack
+	hackres, err := rtprob.RunHack(submission, tests.CreateWrongSubmission(), tests.CreateHack("1 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hackres.Success {
+		t.Fatal("invalid hack result", hackres)
+	}
+	hackres, err = rtprob.RunHack(submission, submission, tests.CreateHack("1 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hackres.Success {
+		t.Fatal("invalid hack result", hackres)
+	}
+	_, err = rtprob.RunHack(submission, submission, problem.Submission{})
+	if !yerrors.Is(err, problemruntime.ErrInvalidHack) {
+		t.Fatal("invalid err", err)
+	}
+	// std 超时或者运行错误时，其输出不能作为答案
+	for _, src := range []string{stdTLE, stdRE} {
+		std := tests.CreateSubmission()
+		std.SetData(workflow.Gsubm, "source", []byte(src))
+		_, err = rtprob.RunHack(std, tests.CreateWrongSubmission(), tests.CreateHack("1 2"))
+		if !yerrors.Is(err, problemruntime.ErrInvalidHack) {
+			t.Fatal("invalid err", err)
+		}
+	}
+
 	// finalize
 	defer rtprob.Finalize()
+
+	// validate
+	if diags := problemruntime.Validate(prob); diags.HasError() {
+		t.Fatal("valid problem reported\n", diags)
+	}
+	prob.AnalyzerName = "unknown"
+	if diags := problemruntime.Validate(prob); !diags.HasError() {
+		t.Fatal("unknown analyzer not reported")
+	}
+}
+
+func TestRtProblemGroups(t *testing.T) {
+	lg := log.NewTest()
+	prob, err := tests.CreateProblem(t.TempDir(), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// checker 取自子任务，runner_config 取自数据组
+	text := preset.Traditional.Text()
+	text = strings.Replace(text, "static.checker", "subtask.checker", 1)
+	text = strings.Replace(text, "static.runner_config", "testset.runner_config", 1)
+	prob.Workflow, err = workflow.ParseText([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := prob.Static.GetData("runner_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prob.Data.Record.SetData("runner_config", conf)
+	prob.Data.Subtasks[0].Record.SetData("checker", []byte(tests.NcmpSource))
+	prob.Data.Subtasks[1].Record.SetData("checker", []byte(wrongChecker))
+
+	rtprob, err := problemruntime.New(prob, t.TempDir(), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtprob.Finalize()
+	res, err := rtprob.RunTestset(rtprob.Data.Data, tests.CreateSubmission())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Subtasks[0].Score != res.Subtasks[0].Fullscore || res.Subtasks[1].Score != 0 {
+		t.Fatal("invalid result", res)
+	}
+}
+
+func TestRunConfOverride(t *testing.T) {
+	lg := log.NewTest()
+	prob, err := tests.CreateProblem(t.TempDir(), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 子任务 0 的测试点 1 改为文件 IO（因而答案错误）；子任务 1 改为文件 IO，但其测试点
+	// 又覆盖了子任务的配置
+	prob.Data.Subtasks[0].Testcases[1].SetData(problemruntime.RunConfField, []byte(`{"Inf": "a.in", "Ouf": "a.out"}`))
+	prob.Data.Subtasks[1].Record.SetData(problemruntime.RunConfField, []byte(`{"Inf": "a.in", "Ouf": "a.out"}`))
+	for _, testcase := range prob.Data.Subtasks[1].Testcases {
+		testcase.SetData(problemruntime.RunConfField, []byte(`{"Inf": "", "Ouf": ""}`))
+	}
+
+	rtprob, err := problemruntime.New(prob, t.TempDir(), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtprob.Finalize()
+	res, err := rtprob.RunTestset(rtprob.Data.Data, tests.CreateSubmission())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests0 := res.Subtasks[0].Testcases
+	if tests0[0].Score != tests0[0].Fullscore || tests0[1].Score == tests0[1].Fullscore {
+		t.Fatal("invalid result", res.Subtasks[0])
+	}
+	if res.Subtasks[1].Score != res.Subtasks[1].Fullscore {
+		t.Fatal("invalid result", res.Subtasks[1])
+	}
+}
+
+// 总是返回 WA 的 checker
+const wrongChecker = `
+#include "testlib.h"
+int main(int argc, char * argv[]) {
+  registerTestlibCmd(argc, argv);
+  quitf(_wa, "always wrong");
 }
+`
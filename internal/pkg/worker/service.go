@@ -36,6 +36,14 @@ type Service struct {
 	store sync.Map
 	// 评测的目录
 	work_dir string
+	// 全局缓存，在所有提交之间共享，服务重启后依然有效
+	cache *workflowruntime.GlobalCache
+	// 远程缓存，可以为 nil
+	remote *workflowruntime.RemoteCache
+	// 题目数据与评测输出共用的文件池
+	pool *data.BlobStore
+	// 加载题目压缩包时的限制
+	limits problem.ExtractLimits
 
 	lg *log.Entry
 }
@@ -69,10 +77,33 @@ func (r *Service) SetProblem(checksum string, reader io.Reader) error {
 		return yerrors.Situated("mkdir temp", err)
 	}
 
-	prob, err := problem.LoadFileTo(file.Name(), prob_dir)
+	// 题目数据按需从压缩包中读取，因此压缩包需要一直保留
+	archive := path.Join(prob_dir, ".problem.zip")
+	if err := os.Rename(file.Name(), archive); err != nil {
+		os.RemoveAll(prob_dir)
+		return yerrors.Situated("move archive", err)
+	}
+	prob, err := problem.LoadZip(archive, prob_dir, r.limits)
 	if err != nil {
+		os.RemoveAll(prob_dir)
 		return yerrors.Situated("load problem file", err)
 	}
+	diagnostics := problemruntime.Validate(prob)
+	for _, d := range diagnostics {
+		if d.Severity == problem.Info {
+			r.lg.WithField("checksum", checksum).Info(d.String())
+		} else {
+			r.lg.WithField("checksum", checksum).Warn(d.String())
+		}
+	}
+	if diagnostics.HasError() {
+		prob.Finalize()
+		return yerrors.Annotated("diagnostics", diagnostics.String(), ErrInvalidProblem)
+	}
+
+	if err := prob.Dedup(r.pool); err != nil {
+		r.lg.WithError(err).Warn("dedup problem data")
+	}
 
 	r.store.Store(checksum, prob)
 	r.lg.Infof("SetProblem checksum=%s prob=%s", checksum, prob_dir)
@@ -87,6 +118,7 @@ func (r *Service) SetProblem(checksum string, reader io.Reader) error {
 func (r *Service) RunProblem(checksum string, submission_data []byte, mode string) (*problem.Result, error) {
 	r.Lock()
 	defer r.Unlock()
+	defer r.prune()
 
 	val, ok := r.store.Load(checksum)
 	if !ok {
@@ -99,6 +131,8 @@ func (r *Service) RunProblem(checksum string, submission_data []byte, mode strin
 		return nil, yerrors.Situated("create RtProblem", err)
 	}
 	defer rtprob.Finalize()
+	rtprob.UseCache(r.cachers()...)
+	rtprob.UsePool(r.pool)
 	// determine testset
 	testset := prob.Data
 	if mode == "pretest" {
@@ -124,9 +158,53 @@ func (r *Service) RunProblem(checksum string, submission_data []byte, mode strin
 	return result, nil
 }
 
+// checksum 为题目数据的校验值
+//
+// std_data 为标准答案，target_data 为被 hack 的提交，hack_data 为 hack 的数据
+// （提交记录的格式，包含 Gtests 域）
+func (r *Service) RunHack(checksum string, std_data, target_data, hack_data []byte) (*problem.HackResult, error) {
+	r.Lock()
+	defer r.Unlock()
+	defer r.prune()
+
+	val, ok := r.store.Load(checksum)
+	if !ok {
+		return nil, yerrors.Annotated("checksum", checksum, ErrNoSuchProblem)
+	}
+	prob := val.(*problem.Data)
+
+	rtprob, err := problemruntime.New(prob, path.Join(r.work_dir, utils.RandomString(8)), r.lg)
+	if err != nil {
+		return nil, yerrors.Situated("create RtProblem", err)
+	}
+	defer rtprob.Finalize()
+	rtprob.UseCache(r.cachers()...)
+	rtprob.UsePool(r.pool)
+
+	std, err := problem.LoadSubmData(std_data)
+	if err != nil {
+		return nil, yerrors.Situated("load std", err)
+	}
+	target, err := problem.LoadSubmData(target_data)
+	if err != nil {
+		return nil, yerrors.Situated("load target", err)
+	}
+	hack, err := problem.LoadSubmData(hack_data)
+	if err != nil {
+		return nil, yerrors.Situated("load hack", err)
+	}
+
+	result, err := rtprob.RunHack(std, target, hack)
+	if err != nil {
+		return nil, yerrors.Situated("run hack", err)
+	}
+	return result, nil
+}
+
 func (r *Service) CustomTest(submission_data []byte) (*workflow.Result, error) {
 	r.Lock()
 	defer r.Unlock()
+	defer r.prune()
 
 	submission, err := problem.LoadSubmData(submission_data)
 	if err != nil {
@@ -151,11 +229,8 @@ func (r *Service) CustomTest(submission_data []byte) (*workflow.Result, error) {
 			Fileno:   10,
 		}).Serialize()),
 	}
-	cache, err := workflowruntime.NewCache(path.Join(dir, "cache"))
-	if err != nil {
-		return nil, err
-	}
-	rtwork.UseCache(cache)
+	rtwork.UseCache(r.cachers()...)
+	rtwork.UsePool(r.pool)
 	result, err := rtwork.Run(inbounds, false)
 	if err != nil {
 		return nil, err
@@ -163,10 +238,33 @@ func (r *Service) CustomTest(submission_data []byte) (*workflow.Result, error) {
 	return result, nil
 }
 
+// 删除文件池中不再使用的文件
+func (r *Service) prune() {
+	if _, err := r.pool.Prune(); err != nil {
+		r.lg.WithError(err).Warn("prune blob store")
+	}
+}
+
+// 按优先级排列的缓存
+func (r *Service) cachers() []workflowruntime.RtNodeCache {
+	cachers := []workflowruntime.RtNodeCache{r.cache}
+	if r.remote != nil {
+		cachers = append(cachers, r.remote)
+	}
+	return cachers
+}
+
 // create a new worker in a dir
 //
 // create the dir if necessary
-func New(dir string, logger *log.Entry) (*Service, error) {
+func New(dir string, logger *log.Entry, options ...OptionProvider) (*Service, error) {
+	var option = Option{
+		ExtractLimits: problem.DefaultExtractLimits,
+	}
+	for _, provider := range options {
+		provider(&option)
+	}
+
 	err := os.MkdirAll(dir, 0777)
 	if err != nil {
 		return nil, err
@@ -184,12 +282,32 @@ func New(dir string, logger *log.Entry) (*Service, error) {
 		return nil, err
 	}
 
+	cache, err := workflowruntime.NewLimitedCache(path.Join(dir, "cache"), option.CacheBudget)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := data.NewBlobStore(path.Join(dir, "blobs"))
+	if err != nil {
+		return nil, err
+	}
+
+	lg := logger.WithField("worker", dir)
+	var remote *workflowruntime.RemoteCache
+	if option.RemoteCache != "" {
+		remote = workflowruntime.NewRemoteCache(option.RemoteCache, option.RemoteCacheToken, option.RemoteCacheTimeout, lg)
+	}
+
 	return &Service{
 		Mutex:    &sync.Mutex{},
 		dir:      dir,
 		data_dir: data_dir,
 		work_dir: work_dir,
+		cache:    cache,
+		remote:   remote,
+		pool:     pool,
+		limits:   option.ExtractLimits,
 		store:    sync.Map{},
-		lg:       logger.WithField("worker", dir),
+		lg:       lg,
 	}, nil
 }
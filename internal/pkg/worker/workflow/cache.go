@@ -1,11 +1,17 @@
 package workflowruntime
 
 import (
+	"container/list"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
 )
 
 // 针对 workflow 的结点的输出结果的缓存
@@ -16,7 +22,7 @@ import (
 type RtNodeCache interface {
 	// add node to cache (by hash)
 	//
-	// 具体是否加入缓存取决于 node 本身
+	// 具体是否加入缓存取决于 node 本身，见 [RtNode.Cachable]
 	Add(node *RtNode) error
 	// check if cache exist
 	Exist(node *RtNode) bool
@@ -24,47 +30,114 @@ type RtNodeCache interface {
 	Assign(node *RtNode) error
 }
 
+// 一条缓存记录
+type cacheEntry struct {
+	key string
+	// 该记录的所有文件名
+	files []string
+	// 文件总大小
+	size int64
+}
+
+// 存储在文件系统中的全局缓存，在多次提交之间共享
+//
+// 缓存目录中 key+".result" 存放结点的执行结果，key+label 存放对应的输出文件。
+// 创建时会根据目录中已有的文件重建索引，因此缓存在服务重启后依然有效。
+//
+// 设置了容量上限时，缓存总大小超出上限后按照 LRU 的顺序淘汰。可以并发使用。
 type GlobalCache struct {
+	*sync.Mutex
 	// 所有缓存数据的存放位置
-	dir   string
-	store map[string]struct{}
+	dir string
+	// 缓存总大小的上限（byte），0 表示不限制
+	budget int64
+	// 当前缓存总大小
+	size int64
+	// 最近使用的记录在表头
+	lru   *list.List
+	store map[string]*list.Element
 }
 
 func (r *GlobalCache) Add(node *RtNode) error {
-	if !node.Cache { // 不缓存
+	if !node.Cachable() { // 不缓存
 		return nil
 	}
+	r.Lock()
+	defer r.Unlock()
 
 	key := node.Hash().String()
-	err := os.WriteFile(path.Join(r.dir, key+".result"), node.Result.Serialize(), 0777)
-	if err != nil {
-		return err
+	if elem, ok := r.store[key]; ok {
+		r.lru.MoveToFront(elem)
+		return nil
 	}
+	entry := &cacheEntry{key: key}
+	// 先写入输出文件，最后写入结果，结果文件存在即表示记录完整
 	for field, store := range node.Output {
-		ctnt, err := store.Get()
-		if err != nil {
-			return err
-		}
-		err = os.WriteFile(path.Join(r.dir, key+field), ctnt, 0777)
+		size, err := r.write(key+field, store)
 		if err != nil {
+			r.remove(entry)
 			return err
 		}
+		entry.files = append(entry.files, key+field)
+		entry.size += size
+	}
+	result := node.Result.Serialize()
+	if err := os.WriteFile(path.Join(r.dir, key+".result"), result, 0644); err != nil {
+		r.remove(entry)
+		return err
 	}
-	r.store[key] = struct{}{}
+	entry.files = append(entry.files, key+".result")
+	entry.size += int64(len(result))
+
+	r.store[key] = r.lru.PushFront(entry)
+	r.size += entry.size
+	r.evict()
 	return nil
 }
 
+// 先写入临时文件再重命名，避免留下不完整的文件
+func (r *GlobalCache) write(name string, store data.Store) (int64, error) {
+	tmp := path.Join(r.dir, ".tmp-"+utils.RandomString(10))
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		return 0, err
+	}
+	size, err := data.Copy(file, store)
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp, path.Join(r.dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return size, nil
+}
+
 func (r *GlobalCache) Exist(node *RtNode) bool {
+	r.Lock()
+	defer r.Unlock()
 	_, exist := r.store[node.Hash().String()]
 	return exist
 }
 
+// 输出文件会以硬链接（不支持时复制）的形式放在当前工作目录下，因此之后
+// 该记录被淘汰也不会影响结点
 func (r *GlobalCache) Assign(node *RtNode) error {
+	r.Lock()
+	defer r.Unlock()
+
 	key := node.Hash().String()
-	node.Output = make(processor.Outbounds)
-	for _, field := range processor.OutputLabel(node.ProcName) {
-		node.Output[field] = data.NewFileFile(path.Join(r.dir, key+field))
+	elem, ok := r.store[key]
+	if !ok {
+		return os.ErrNotExist
 	}
+	r.lru.MoveToFront(elem)
+
 	res_ctnt, err := os.ReadFile(path.Join(r.dir, key+".result"))
 	if err != nil {
 		return err
@@ -74,14 +147,129 @@ func (r *GlobalCache) Assign(node *RtNode) error {
 	if err != nil {
 		return err
 	}
+	// 记录最近使用的时间，用于重建索引
+	now := time.Now()
+	os.Chtimes(path.Join(r.dir, key+".result"), now, now)
+
+	node.Output = make(processor.Outbounds)
+	for _, field := range processor.OutputLabel(node.ProcName) {
+		name := utils.RandomString(10)
+		if err := os.Link(path.Join(r.dir, key+field), name); err != nil {
+			if _, err := utils.CopyFile(path.Join(r.dir, key+field), name); err != nil {
+				return err
+			}
+		}
+		node.Output[field] = data.NewFileFile(name)
+	}
 	return nil
 }
 
+// 缓存的总大小（byte）
+func (r *GlobalCache) Size() int64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.size
+}
+
+// 缓存的记录数
+func (r *GlobalCache) Len() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.lru.Len()
+}
+
+// 淘汰最久未使用的记录直到总大小不超过上限，最近使用的记录总是保留
+func (r *GlobalCache) evict() {
+	for r.budget > 0 && r.size > r.budget && r.lru.Len() > 1 {
+		elem := r.lru.Back()
+		entry := elem.Value.(*cacheEntry)
+		r.lru.Remove(elem)
+		delete(r.store, entry.key)
+		r.size -= entry.size
+		r.remove(entry)
+	}
+}
+
+func (r *GlobalCache) remove(entry *cacheEntry) {
+	for _, name := range entry.files {
+		os.Remove(path.Join(r.dir, name))
+	}
+}
+
+// 根据缓存目录中的文件重建索引，删除不完整的记录和临时文件
+func (r *GlobalCache) load() error {
+	dirents, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+	var entries = map[string]*cacheEntry{}
+	var used = map[string]time.Time{}
+	for _, dirent := range dirents {
+		name := dirent.Name()
+		info, err := dirent.Info()
+		if err != nil {
+			return err
+		}
+		if dirent.IsDir() || len(name) < keyLength || strings.HasPrefix(name, ".tmp-") {
+			os.RemoveAll(path.Join(r.dir, name))
+			continue
+		}
+		key := name[:keyLength]
+		if entries[key] == nil {
+			entries[key] = &cacheEntry{key: key}
+		}
+		entries[key].files = append(entries[key].files, name)
+		entries[key].size += info.Size()
+		if name == key+".result" {
+			used[key] = info.ModTime()
+		}
+	}
+	var keys []string
+	for key, entry := range entries {
+		if _, ok := used[key]; !ok {
+			r.remove(entry)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return used[keys[i]].Before(used[keys[j]])
+	})
+	for _, key := range keys {
+		r.store[key] = r.lru.PushFront(entries[key])
+		r.size += entries[key].size
+	}
+	r.evict()
+	return nil
+}
+
+// SHA 的十六进制表示的长度
+const keyLength = 64
+
 // create dir if necessary
 func NewCache(dir string) (*GlobalCache, error) {
+	return NewLimitedCache(dir, 0)
+}
+
+// 创建容量上限为 budget（byte）的缓存，0 表示不限制
+//
+// create dir if necessary
+func NewLimitedCache(dir string, budget int64) (*GlobalCache, error) {
 	err := os.MkdirAll(dir, 0750)
 	if err != nil {
 		return nil, err
 	}
-	return &GlobalCache{dir: dir, store: map[string]struct{}{}}, nil
+	res := &GlobalCache{
+		Mutex:  &sync.Mutex{},
+		dir:    dir,
+		budget: budget,
+		lru:    list.New(),
+		store:  map[string]*list.Element{},
+	}
+	if err := res.load(); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
+
+var _ RtNodeCache = (*GlobalCache)(nil)
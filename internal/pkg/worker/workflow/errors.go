@@ -5,4 +5,5 @@ import "github.com/super-yaoj/yaoj-core/pkg/yerrors"
 var (
 	ErrNilInboundGroup = yerrors.New("invalid nil inboundgroup")
 	ErrIncompleteInput = yerrors.New("incomplete node input")
+	ErrRemoteCache     = yerrors.New("invalid remote cache response")
 )
@@ -2,8 +2,11 @@ package workflowruntime
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"hash"
+
+	"github.com/super-yaoj/yaoj-core/pkg/data"
 )
 
 type shaHash struct {
@@ -24,6 +27,31 @@ func (r *shaHash) WriteString(s string) (n int, err error) {
 	return r.Write([]byte(s))
 }
 
+// 写入长度（8 字节大端序）和内容，使得不同的切分方式不会产生相同的哈希
+func (r *shaHash) WriteFrame(b []byte) {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(b)))
+	r.Write(size[:])
+	r.Write(b)
+}
+
+// 流式地写入长度和内容
+func (r *shaHash) WriteFrameStore(store data.Store) error {
+	size, err := store.Size()
+	if err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(size))
+	r.Write(buf[:])
+	_, err = data.Copy(r, store)
+	return err
+}
+
+func (r *shaHash) WriteFrameString(s string) {
+	r.WriteFrame([]byte(s))
+}
+
 func newShaHash() *shaHash {
 	return &shaHash{Hash: sha256.New()}
 }
@@ -0,0 +1,250 @@
+package workflowruntime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/super-yaoj/yaoj-core/pkg/data"
+	"github.com/super-yaoj/yaoj-core/pkg/log"
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// 远程缓存中一个结点的记录
+type RemoteManifest struct {
+	// 序列化后的 processor.Result
+	Result []byte `json:"result"`
+	// 输出的 label 及其内容的 sha256（十六进制）
+	Output map[string]string `json:"output"`
+}
+
+// 基于 HTTP 的远程缓存，用于在多台评测机之间共享缓存
+//
+// 协议是内容寻址的：
+//
+//   - HEAD/GET/PUT /blob/:sum 文件内容，sum 为其 sha256 的十六进制表示
+//   - HEAD/GET/PUT /node/:key 结点的记录（[RemoteManifest] 的 JSON），key 为结点的哈希
+//
+// 服务端应当拒绝 sum 与内容不符的 blob 以及引用了不存在的 blob 的记录。参考实现
+// 见 internal/app/cacheserver。
+//
+// 远程缓存只是加速手段，因此 Exist 和 Add 遇到网络错误时只记录日志，不会中断评测；
+// Assign 失败（例如 blob 损坏）时，结点会被直接执行。
+//
+// 缓存中的执行结果会被直接采用，因此缓存服务只能对可信的评测端开放，并且应当
+// 设置共享的 token（每个请求都会带上 "Authorization: Bearer <token>"）。
+type RemoteCache struct {
+	addr   string
+	token  string
+	client *http.Client
+	lg     *log.Entry
+}
+
+func (r *RemoteCache) url(kind, key string) string {
+	return r.addr + "/" + kind + "/" + key
+}
+
+func (r *RemoteCache) do(method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	return r.client.Do(req)
+}
+
+// 对应的资源是否存在
+func (r *RemoteCache) head(kind, key string) (bool, error) {
+	resp, err := r.do(http.MethodHead, r.url(kind, key), nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, yerrors.Annotated("status", resp.Status, ErrRemoteCache)
+	}
+}
+
+func (r *RemoteCache) get(kind, key string) ([]byte, error) {
+	resp, err := r.do(http.MethodGet, r.url(kind, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, yerrors.Annotated("status", resp.Status, ErrRemoteCache)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (r *RemoteCache) put(kind, key string, body io.Reader) error {
+	resp, err := r.do(http.MethodPut, r.url(kind, key), body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return yerrors.Annotated("status", resp.Status, ErrRemoteCache)
+	}
+	return nil
+}
+
+func (r *RemoteCache) putStore(sum string, store data.Store) error {
+	src, err := store.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return r.put("blob", sum, src)
+}
+
+func (r *RemoteCache) Add(node *RtNode) error {
+	if !node.Cachable() { // 不缓存
+		return nil
+	}
+	if err := r.add(node); err != nil {
+		r.lg.WithError(err).Warn("add to remote cache")
+	}
+	return nil
+}
+
+func (r *RemoteCache) add(node *RtNode) error {
+	manifest := RemoteManifest{
+		Result: node.Result.Serialize(),
+		Output: map[string]string{},
+	}
+	for label, store := range node.Output {
+		sum, err := storeSum(store)
+		if err != nil {
+			return err
+		}
+		// 内容寻址，已经存在的文件无需重复上传
+		exist, err := r.head("blob", sum)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			if err := r.putStore(sum, store); err != nil {
+				return yerrors.Situated("put blob", err)
+			}
+		}
+		manifest.Output[label] = sum
+	}
+	ctnt, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := r.put("node", node.Hash().String(), bytes.NewReader(ctnt)); err != nil {
+		return yerrors.Situated("put node", err)
+	}
+	return nil
+}
+
+func (r *RemoteCache) Exist(node *RtNode) bool {
+	exist, err := r.head("node", node.Hash().String())
+	if err != nil {
+		r.lg.WithError(err).Warn("check remote cache")
+		return false
+	}
+	return exist
+}
+
+func (r *RemoteCache) Assign(node *RtNode) error {
+	ctnt, err := r.get("node", node.Hash().String())
+	if err != nil {
+		return yerrors.Situated("get node", err)
+	}
+	var manifest RemoteManifest
+	if err := json.Unmarshal(ctnt, &manifest); err != nil {
+		return err
+	}
+	result := &processor.Result{}
+	if err := result.Deserialize(manifest.Result); err != nil {
+		return err
+	}
+
+	output := make(processor.Outbounds)
+	for _, label := range processor.OutputLabel(node.ProcName) {
+		sum, ok := manifest.Output[label]
+		if !ok {
+			return yerrors.Annotated("label", label, ErrRemoteCache)
+		}
+		name := utils.RandomString(10)
+		if err := r.download(sum, name); err != nil {
+			return yerrors.Situated("get blob", err)
+		}
+		output[label] = data.NewFileFile(name)
+	}
+	node.Result = result
+	node.Output = output
+	return nil
+}
+
+// 下载 blob 到文件 name 并校验
+func (r *RemoteCache) download(sum, name string) error {
+	resp, err := r.do(http.MethodGet, r.url("blob", sum), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return yerrors.Annotated("status", resp.Status, ErrRemoteCache)
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), resp.Body); err != nil {
+		return err
+	}
+	if fmt.Sprintf("%x", hash.Sum(nil)) != sum {
+		return yerrors.Annotated("blob", sum, ErrRemoteCache)
+	}
+	return nil
+}
+
+// 流式地计算内容的 sha256
+func storeSum(store data.Store) (string, error) {
+	hash := sha256.New()
+	if _, err := data.Copy(hash, store); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// 远程缓存每个请求（包括读取响应）的默认超时时间
+const DefaultRemoteTimeout = 30 * time.Second
+
+// addr 为缓存服务的地址，例如 "http://localhost:3100"，token 为共享的 token
+//
+// timeout 为每个请求的超时时间，0 表示使用 [DefaultRemoteTimeout]
+func NewRemoteCache(addr string, token string, timeout time.Duration, logger *log.Entry) *RemoteCache {
+	if timeout == 0 {
+		timeout = DefaultRemoteTimeout
+	}
+	return &RemoteCache{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+		lg:     logger.WithField("remote_cache", addr),
+	}
+}
+
+var _ RtNodeCache = (*RemoteCache)(nil)
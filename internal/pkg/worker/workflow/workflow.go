@@ -3,6 +3,8 @@ package workflowruntime
 import (
 	"errors"
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/super-yaoj/yaoj-core/internal/pkg/processors"
 	"github.com/super-yaoj/yaoj-core/pkg/data"
@@ -31,6 +33,8 @@ type RtNode struct {
 	Attr map[string]string
 	// result of processor
 	Result *processor.Result
+	// 是否因为执行条件不满足（或者上游结点被跳过）而被跳过，此时 Result 为 nil
+	Skipped bool
 
 	// hash is calculated during workflow testing
 	hash *SHA
@@ -39,28 +43,57 @@ type RtNode struct {
 	lg *log.Entry
 }
 
+// 是否应当加入缓存
+//
+// 缓存在多次提交之间共享，因此只缓存确定的结果：系统错误与超时可能只是因为
+// 评测机的状态（例如负载过高），不会被缓存
+func (r *RtNode) Cachable() bool {
+	if !r.Cache || r.Result == nil {
+		return false
+	}
+	return r.Result.Code != processor.SystemError && r.Result.Code != processor.TimeExceed
+}
+
+// 哈希格式的版本，修改哈希的计算方式时需要更新，使得旧的缓存失效
+const hashFormat = "yaoj-node-hash/3"
+
 // sum up hash of all input files and the node its self
 //
 // should be invoked after all inputs getting ready
 //
-// 算哈希的时候不考虑 nil input 的情况
+// 每个输入依次写入 label、是否为 nil 以及带长度前缀的内容，然后写入
+// processor 的名字和版本（见 [processor.Versioner]），最后按 key 的顺序写入
+// 结点的参数
 func (r *RtNode) Hash() SHA {
 	if r.hash == nil {
 		hash := newShaHash()
+		hash.WriteFrameString(hashFormat)
 		for _, name := range processor.InputLabel(r.ProcName) {
+			hash.WriteFrameString(name)
 			store := r.Input[name]
 			if store == nil {
 				r.lg.WithField("input", name).Warn("nil input")
-			} else {
-				data, err := store.Get()
-				if err == nil {
-					hash.Write(data)
-				} else {
-					r.lg.WithError(err).Warn("error getting store")
-				}
+				hash.Write([]byte{0})
+				continue
 			}
+			hash.Write([]byte{1})
+			if err := hash.WriteFrameStore(store); err != nil {
+				// 读取失败时使用随机的内容，保证不会命中缓存
+				r.lg.WithError(err).Warn("error reading store")
+				hash.WriteFrameString(utils.RandomString(32))
+			}
+		}
+		hash.WriteFrameString(r.ProcName)
+		version := ""
+		if versioner, ok := processors.Get(r.ProcName).(processor.Versioner); ok {
+			version = versioner.Version()
+		}
+		hash.WriteFrameString(version)
+		hash.WriteFrameString(strconv.Itoa(len(r.Params)))
+		for _, key := range r.Params.Keys() {
+			hash.WriteFrameString(key)
+			hash.WriteFrameString(r.Params[key])
 		}
-		hash.WriteString(r.ProcName)
 		value := hash.SHA()
 		r.hash = &value
 	}
@@ -68,16 +101,25 @@ func (r *RtNode) Hash() SHA {
 	return *r.hash
 }
 
+// 缓存只是加速手段，因此读取缓存失败时记录日志，然后尝试下一个缓存或者直接执行
+// processor；写入缓存失败时也只记录日志
 func (r *RtNode) run(name string, cachers []RtNodeCache) error {
 	cached := false
-	for _, cacher := range cachers {
-		if cacher.Exist(r) {
-			if err := cacher.Assign(r); err != nil {
-				return yerrors.Situated("cacher.Assign", err)
-			}
-			cached = true
-			break
+	for i, cacher := range cachers {
+		if !cacher.Exist(r) {
+			continue
+		}
+		if err := cacher.Assign(r); err != nil {
+			r.lg.WithError(err).Warn("assign from cache")
+			// 丢弃读取了一部分的结果
+			r.Result = nil
+			r.Output = make(processor.Outbounds)
+			continue
 		}
+		cached = true
+		// 回填优先级更高的缓存
+		r.addCache(cachers[:i])
+		break
 	}
 	if !cached {
 		// check input complete
@@ -91,28 +133,39 @@ func (r *RtNode) run(name string, cachers []RtNodeCache) error {
 		for _, label := range processor.OutputLabel(r.ProcName) {
 			r.Output[label] = data.NewFile(utils.RandomString(10), nil)
 		}
-		r.Result = processors.Get(r.ProcName).Process(r.Input, r.Output)
+		r.Result = processors.Get(r.ProcName).Process(r.Input, r.Output, r.Params)
+		r.addCache(cachers)
 	}
-	if !cached {
-		for _, cacher := range cachers {
-			if err := cacher.Add(r); err != nil {
-				return yerrors.Situated("cacher.Add", err)
-			}
+	return nil
+}
+
+// 将结点写入缓存，失败时只记录日志
+func (r *RtNode) addCache(cachers []RtNodeCache) {
+	for _, cacher := range cachers {
+		if err := cacher.Add(r); err != nil {
+			r.lg.WithError(err).Warn("add to cache")
 		}
 	}
-	return nil
 }
 
 type RtWorkflow struct {
+	// 展开复合结点后的 workflow，见 [workflow.Workflow.Expand]
 	*workflow.Workflow
+	// 结点名为展开后的名字，例如 "checker/compile"
 	RtNodes   map[string]*RtNode
 	Fullscore float64
+	// 最近一次 Run 时绑定的读入数据
+	Inbounds workflow.InboundGroups
 	// runtime working dir
 	dir string
 	// 外部提供的缓存，下标越小优先级越高
 	caches []RtNodeCache
+	// 结点输出存入的文件池，可以为 nil
+	pool *data.BlobStore
 	// node names sorted topologically
 	sortedNames []string
+	// 展开前的 workflow
+	origin *workflow.Workflow
 
 	analyzer Analyzer
 
@@ -129,16 +182,21 @@ func New(wk *Workflow, dir string, fullscore float64, analyzer Analyzer, logger
 		return nil, err
 	}
 	logger = logger.WithField("workflow", dir)
+	expanded, err := wk.Expand()
+	if err != nil {
+		return nil, yerrors.Situated("expand", err)
+	}
 
 	res := &RtWorkflow{
-		Workflow:  wk,
+		Workflow:  expanded,
+		origin:    wk,
 		RtNodes:   map[string]*RtNode{},
 		Fullscore: fullscore,
 		dir:       dir,
 		lg:        logger,
 		analyzer:  analyzer,
 	}
-	for name, node := range wk.Node {
+	for name, node := range expanded.Node {
 		res.sortedNames = append(res.sortedNames, name)
 		res.RtNodes[name] = &RtNode{
 			Node:   node,
@@ -149,12 +207,7 @@ func New(wk *Workflow, dir string, fullscore float64, analyzer Analyzer, logger
 		}
 	}
 	sorted, err := utils.TopSort(res.sortedNames, func(u, v string) bool {
-		for _, e := range wk.EdgeFrom(u) {
-			if e.To.Name == v {
-				return true
-			}
-		}
-		return false
+		return expanded.DependsOn(v, u)
 	})
 	if err != nil {
 		return nil, yerrors.Situated("topsort", err)
@@ -164,23 +217,39 @@ func New(wk *Workflow, dir string, fullscore float64, analyzer Analyzer, logger
 }
 
 // append cachers
+//
+// 按照添加的顺序查找缓存，命中时会回填到之前的缓存中
 func (r *RtWorkflow) UseCache(cachers ...RtNodeCache) {
 	r.caches = append(r.caches, cachers...)
 }
 
+// 结点执行后将其输出存入文件池，相同内容的输出共享同一份数据
+func (r *RtWorkflow) UsePool(pool *data.BlobStore) {
+	r.pool = pool
+}
+
 // make sure to pass logger by context
 //
 // dismiss_incomplete: 如果是在 hack 评测时跑 std，那么我们允许不完整的读入
 // 在此模式下如果一个 processor 的读入不完整，那么它就不会被执行（即 result 是 nil）
 func (r *RtWorkflow) Run(inbounds workflow.InboundGroups, dismiss_incomplete bool) (*workflow.Result, error) {
+	r.Inbounds = inbounds
+	workdir, err := filepath.Abs(r.dir)
+	if err != nil {
+		return nil, yerrors.Situated("filepath.Abs", err)
+	}
 	// bind inbound to workflow
 	for gname, group := range r.Inbound {
 		if group == nil {
 			panic("invalid workflow inbound")
 		}
-		if data := inbounds[gname]; data != nil {
+		if stores := inbounds[gname]; stores != nil {
 			for j, bounds := range group {
-				if store, ok := data[j]; ok {
+				if store, ok := stores[j]; ok {
+					// 压缩的数据解压到工作目录中，随 Finalize 删除
+					if compressed, ok := store.(*data.Compressed); ok {
+						store = compressed.In(workdir)
+					}
 					for _, bound := range bounds {
 						r.RtNodes[bound.Name].Input[bound.Label] = store
 					}
@@ -211,6 +280,11 @@ func (r *RtWorkflow) Run(inbounds workflow.InboundGroups, dismiss_incomplete boo
 	r.lg.Debug("change working dir")
 
 	for _, name := range r.sortedNames {
+		if r.skip(name) {
+			r.lg.WithField("node", name).Debug("skip node")
+			r.RtNodes[name].Skipped = true
+			continue
+		}
 		err := r.RtNodes[name].run(name, r.caches)
 		if errors.Is(err, ErrIncompleteInput) && dismiss_incomplete {
 			r.lg.WithField("node", name).Debug("dismiss incomplete input")
@@ -218,6 +292,13 @@ func (r *RtWorkflow) Run(inbounds workflow.InboundGroups, dismiss_incomplete boo
 		} else if err != nil {
 			return nil, yerrors.Annotated("node", name, err)
 		}
+		if r.pool != nil {
+			for label, store := range r.RtNodes[name].Output {
+				if err := r.pool.Dedup(store.Path()); err != nil {
+					r.lg.WithError(err).WithField("node", name).WithField("label", label).Warn("dedup output")
+				}
+			}
+		}
 		for _, edge := range r.EdgeFrom(name) {
 			r.RtNodes[edge.To.Name].Input[edge.To.Label] = r.RtNodes[edge.From.Name].Output[edge.From.Label]
 		}
@@ -227,6 +308,70 @@ func (r *RtWorkflow) Run(inbounds workflow.InboundGroups, dismiss_incomplete boo
 	return &res, nil
 }
 
+// 输出端口对应的文件，复合结点的输出会被转化为对应的内部结点的输出
+//
+// 结点没有执行时为 nil
+func (r *RtWorkflow) Output(bound workflow.Outbound) data.FileStore {
+	resolved, err := r.origin.Resolve(bound)
+	if err != nil {
+		return nil
+	}
+	node, ok := r.RtNodes[resolved.Name]
+	if !ok {
+		return nil
+	}
+	return node.Output[resolved.Label]
+}
+
+// 输出端口所依赖的结点，即产生它的结点及其（沿着边的）所有上游结点，按拓扑序
+// 排列，复合结点的输出会被转化为对应的内部结点的输出
+func (r *RtWorkflow) Upstream(bound workflow.Outbound) []string {
+	resolved, err := r.origin.Resolve(bound)
+	if err != nil {
+		return nil
+	}
+	if _, ok := r.RtNodes[resolved.Name]; !ok {
+		return nil
+	}
+	needed := map[string]bool{resolved.Name: true}
+	var res []string
+	for i := len(r.sortedNames) - 1; i >= 0; i-- {
+		name := r.sortedNames[i]
+		if !needed[name] {
+			continue
+		}
+		res = append([]string{name}, res...)
+		for _, edge := range r.EdgeTo(name) {
+			needed[edge.From.Name] = true
+		}
+	}
+	return res
+}
+
+// 结点是否需要跳过：某个执行条件不满足，或者某个上游结点被跳过
+func (r *RtWorkflow) skip(name string) bool {
+	for _, edge := range r.EdgeTo(name) {
+		if r.RtNodes[edge.From.Name].Skipped {
+			return true
+		}
+	}
+	for _, cond := range r.RtNodes[name].Conditions {
+		node := r.RtNodes[cond.Node]
+		if node == nil || node.Result == nil {
+			return true
+		}
+		code, err := processor.ParseCode(cond.Code)
+		if err != nil {
+			r.lg.WithError(err).WithField("node", name).Warn("invalid condition")
+			return true
+		}
+		if (node.Result.Code == code) == cond.Not {
+			return true
+		}
+	}
+	return false
+}
+
 // 删除所有文件（销毁自身）
 func (r *RtWorkflow) Finalize() error {
 	err := os.RemoveAll(r.dir)
@@ -1,7 +1,10 @@
 package workflowruntime_test
 
 import (
+	"errors"
+	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/super-yaoj/yaoj-core/internal/pkg/analyzers"
@@ -9,16 +12,17 @@ import (
 	"github.com/super-yaoj/yaoj-core/internal/tests"
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/log"
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow/preset"
-	utils "github.com/super-yaoj/yaoj-utils"
+	yutils "github.com/super-yaoj/yaoj-utils"
 )
 
 var input = `114 514`
 var output = `628`
 
-func TestRtWorkflow(t *testing.T) {
-	lg := log.NewTest()
+func createInbounds(t *testing.T) workflow.InboundGroups {
 	dir := t.TempDir()
 	inbounds := workflow.InboundGroups{
 		workflow.Gstatic: make(map[string]data.FileStore),
@@ -27,7 +31,7 @@ func TestRtWorkflow(t *testing.T) {
 	}
 	inbounds[workflow.Gsubm]["source"] = data.NewFile(path.Join(dir, "_main.cpp"), []byte(tests.APlusBSourceCpp))
 	inbounds[workflow.Gsubm]["option"] = data.NewFile(path.Join(dir, "_cpl"), (&data.CompileConf{
-		Lang: utils.Lcpp11,
+		Lang: yutils.Lcpp11,
 	}).Serialize())
 
 	inbounds[workflow.Gstatic]["checker"] = data.NewFile(path.Join(dir, "_chk.cpp"), []byte(tests.NcmpSource))
@@ -41,6 +45,12 @@ func TestRtWorkflow(t *testing.T) {
 	}).Serialize())
 	inbounds[workflow.Gtests]["input"] = data.NewFile(path.Join(dir, "_input"), []byte(input))
 	inbounds[workflow.Gtests]["output"] = data.NewFile(path.Join(dir, "_output"), []byte(output))
+	return inbounds
+}
+
+func TestRtWorkflow(t *testing.T) {
+	lg := log.NewTest()
+	inbounds := createInbounds(t)
 
 	cache, err := workflowruntime.NewCache(t.TempDir())
 	if err != nil {
@@ -71,3 +81,269 @@ func TestRtWorkflow(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// 总是命中但是读写都失败的缓存
+type brokenCache struct{}
+
+func (brokenCache) Add(node *workflowruntime.RtNode) error {
+	return errors.New("broken cache")
+}
+
+func (brokenCache) Exist(node *workflowruntime.RtNode) bool {
+	return true
+}
+
+func (brokenCache) Assign(node *workflowruntime.RtNode) error {
+	return errors.New("broken cache")
+}
+
+func TestBrokenCache(t *testing.T) {
+	lg := log.NewTest()
+	cache, err := workflowruntime.NewCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk, err := workflowruntime.New(&preset.Traditional, t.TempDir(), 100, analyzers.Traditional{}, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 读取失败时执行 processor，回填失败不影响评测
+	wk.UseCache(brokenCache{}, cache)
+	res, err := wk.Run(createInbounds(t), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Title != "Accepted" {
+		t.Fatal("invalid result", res)
+	}
+	for name, node := range wk.RtNodes {
+		if node.Cache && !cache.Exist(node) {
+			t.Fatal("cache missing", name)
+		}
+	}
+}
+
+func TestConditions(t *testing.T) {
+	inbounds := createInbounds(t)
+	inbounds[workflow.Gsubm]["source"] = data.NewFile(path.Join(t.TempDir(), "_main.cpp"), []byte("int main( {"))
+
+	wk, err := workflowruntime.New(&preset.Traditional, t.TempDir(), 100, analyzers.Traditional{}, log.NewTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wk.Finalize()
+	res, err := wk.Run(inbounds, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Title != "Compile Error" {
+		t.Fatal("invalid result", res)
+	}
+	for name, skipped := range map[string]bool{
+		"compile":         false,
+		"checker_compile": false,
+		"run":             true,
+		"check":           true,
+	} {
+		node := wk.RtNodes[name]
+		if node.Skipped != skipped || (node.Result == nil) != skipped {
+			t.Fatal("invalid node", name, node.Skipped, node.Result)
+		}
+	}
+}
+
+func TestGlobalCache(t *testing.T) {
+	lg := log.NewTest()
+	inbounds := createInbounds(t)
+	dir := t.TempDir()
+
+	cache, err := workflowruntime.NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk, err := workflowruntime.New(&preset.Traditional, t.TempDir(), 100, analyzers.Traditional{}, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk.UseCache(cache)
+	if _, err := wk.Run(inbounds, false); err != nil {
+		t.Fatal(err)
+	}
+	// compile, checker_compile
+	if cache.Len() != 2 {
+		t.Fatal("invalid cache length", cache.Len())
+	}
+
+	// incomplete entry and temporary file should be removed
+	incomplete := path.Join(dir, strings.Repeat("0", 64)+"result")
+	os.WriteFile(incomplete, []byte("data"), 0644)
+	os.WriteFile(path.Join(dir, ".tmp-0000"), []byte("data"), 0644)
+
+	// rebuild index from disk
+	cache2, err := workflowruntime.NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache2.Len() != cache.Len() || cache2.Size() != cache.Size() {
+		t.Fatal("invalid rebuilt cache", cache2.Len(), cache2.Size())
+	}
+	if _, err := os.Stat(incomplete); !os.IsNotExist(err) {
+		t.Fatal("incomplete entry not removed")
+	}
+	for name := range wk.RtNodes {
+		if wk.RtNodes[name].Cache && !cache2.Exist(wk.RtNodes[name]) {
+			t.Fatal("cache missing", name)
+		}
+	}
+
+	// cached outputs are linked into the working dir
+	wk2, err := workflowruntime.New(&preset.Traditional, t.TempDir(), 100, analyzers.Traditional{}, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk2.UseCache(cache2)
+	res, err := wk2.Run(inbounds, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Title != "Accepted" {
+		t.Fatal("invalid result", res)
+	}
+
+	// transient failures are not cached
+	wk3, err := workflowruntime.New(&preset.Traditional, t.TempDir(), 100, analyzers.Traditional{}, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wk3.Run(createInbounds(t), false); err != nil {
+		t.Fatal(err)
+	}
+	empty, err := workflowruntime.NewCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := wk3.RtNodes["compile"]
+	for _, code := range []processor.Code{processor.SystemError, processor.TimeExceed} {
+		node.Result = &processor.Result{Code: code}
+		if err := empty.Add(node); err != nil {
+			t.Fatal(err)
+		}
+		if empty.Exist(node) {
+			t.Fatal("transient result cached", code)
+		}
+	}
+
+	// evict least recently used entries
+	cache3, err := workflowruntime.NewLimitedCache(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache3.Len() != 1 {
+		t.Fatal("invalid cache length", cache3.Len())
+	}
+}
+
+func TestRtNodeHash(t *testing.T) {
+	lg := log.NewTest()
+	dir := t.TempDir()
+	// hash of the compile node with given inputs
+	hash := func(source, option []byte, params ...processor.Params) workflowruntime.SHA {
+		wk, err := workflowruntime.New(&preset.Traditional, t.TempDir(), 100, analyzers.Traditional{}, lg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := wk.RtNodes["compile"]
+		node.Input = processor.Inbounds{}
+		if len(params) > 0 {
+			node.Params = params[0]
+		}
+		if source != nil {
+			node.Input["source"] = data.NewFile(path.Join(dir, utils.RandomString(10)), source)
+		}
+		if option != nil {
+			node.Input["option"] = data.NewFile(path.Join(dir, utils.RandomString(10)), option)
+		}
+		return node.Hash()
+	}
+
+	if hash([]byte("ab"), []byte("c")) != hash([]byte("ab"), []byte("c")) {
+		t.Fatal("hash not deterministic")
+	}
+	if hash([]byte("ab"), []byte("c")) == hash([]byte("a"), []byte("bc")) {
+		t.Fatal("different splits collide")
+	}
+	if hash(nil, []byte("c")) == hash([]byte{}, []byte("c")) {
+		t.Fatal("nil input collides with empty input")
+	}
+	if hash([]byte("a"), []byte("c"), processor.Params{"flags": "-O2"}) == hash([]byte("a"), []byte("c")) {
+		t.Fatal("params not hashed")
+	}
+	if hash([]byte("a"), []byte("c"), processor.Params{"a": "b=c"}) == hash([]byte("a"), []byte("c"), processor.Params{"a=b": "c"}) {
+		t.Fatal("different params collide")
+	}
+}
+
+type analyzerFunc func(w *workflowruntime.RtWorkflow) workflow.Result
+
+func (f analyzerFunc) Analyze(w *workflowruntime.RtWorkflow) workflow.Result {
+	return f(w)
+}
+
+func TestComposite(t *testing.T) {
+	var inner workflow.Builder
+	inner.SetNode("compile", "compiler:testlib", false, true)
+	inner.SetNode("check", "checker:testlib", false, false)
+	inner.AddInbound(workflow.Gstatic, "checker", "compile", "source")
+	inner.AddInbound(workflow.Gtests, "input", "check", "input")
+	inner.AddInbound(workflow.Gtests, "output", "check", "output")
+	inner.AddInbound(workflow.Gtests, "answer", "check", "answer")
+	inner.AddEdge("compile", "result", "check", "checker")
+	checker, err := inner.Workflow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var builder workflow.Builder
+	builder.SetNode("compile", "compiler:auto", false, true)
+	builder.SetNode("run", "runner:auto", false, false)
+	builder.SetComposite("checker", checker, workflow.Outbounds{
+		"report": {Name: "check", Label: "xmlreport"},
+	})
+	builder.AddCondition("checker", workflow.Condition{Node: "run", Code: "Ok"})
+	builder.AddInbound(workflow.Gsubm, "source", "compile", "source")
+	builder.AddInbound(workflow.Gsubm, "option", "compile", "option")
+	builder.AddInbound(workflow.Gtests, "input", "run", "stdin")
+	builder.AddInbound(workflow.Gstatic, "runner_config", "run", "conf")
+	builder.AddInbound(workflow.Gstatic, "checker", "checker", "checker")
+	builder.AddInbound(workflow.Gtests, "input", "checker", "input")
+	builder.AddInbound(workflow.Gtests, "output", "checker", "answer")
+	builder.AddEdge("compile", "result", "run", "executable")
+	builder.AddEdge("run", "stdout", "checker", "output")
+	work, err := builder.Workflow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := analyzerFunc(func(w *workflowruntime.RtWorkflow) workflow.Result {
+		node := w.RtNodes["checker"+workflow.Separator+"check"]
+		if node == nil || node.Result == nil || !node.Result.Ok() {
+			return workflow.Result{ResultMeta: workflow.ResultMeta{Title: "Wrong Answer"}}
+		}
+		if w.Output(workflow.Outbound{Name: "checker", Label: "report"}) == nil {
+			return workflow.Result{ResultMeta: workflow.ResultMeta{Title: "Missing Report"}}
+		}
+		return workflow.Result{ResultMeta: workflow.ResultMeta{Title: "Accepted"}}
+	})
+	wk, err := workflowruntime.New(work, t.TempDir(), 100, analyzer, log.NewTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wk.Finalize()
+	res, err := wk.Run(createInbounds(t), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Title != "Accepted" {
+		t.Fatal("invalid result", res)
+	}
+}
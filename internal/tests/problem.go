@@ -6,9 +6,10 @@ import (
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/log"
 	"github.com/super-yaoj/yaoj-core/pkg/problem"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow/preset"
-	utils "github.com/super-yaoj/yaoj-utils"
+	yutils "github.com/super-yaoj/yaoj-utils"
 )
 
 // testlib ncmp 源码
@@ -67,6 +68,18 @@ int main() {
 }
 `
 
+// a+b 问题错误的源码c++（输出 a - b）
+var APlusBWrongSourceCpp = `
+#include<bits/stdc++.h>
+using namespace std;
+int main() {
+	int a, b;
+	cin >> a >> b;
+	cout << a - b << endl;
+	return 0;
+}
+`
+
 // 创建一个 a+b problem
 //
 // create dir if necessary
@@ -127,6 +140,17 @@ func CreateProblem(dir string, lg *log.Entry) (*problem.Data, error) {
 		Fileno:   5,
 	}).Serialize())
 
+	// setup hack: 提交 input，由 std 的输出得到 output
+	prob.HackFields = problem.SubmConf{
+		"input": {
+			Length:   1024,
+			Accepted: utils.Cplain,
+		},
+	}
+	prob.HackIOMap = workflow.Outbounds{
+		"output": {Name: "run", Label: "stdout"},
+	}
+
 	return prob, nil
 }
 
@@ -136,7 +160,24 @@ func CreateSubmission() problem.Submission {
 	submission := problem.Submission{}
 	submission.SetData(workflow.Gsubm, "source", []byte(APlusBSourceCpp))
 	submission.SetData(workflow.Gsubm, "option", (&data.CompileConf{
-		Lang: utils.Lcpp11,
+		Lang: yutils.Lcpp11,
+	}).Serialize())
+	return submission
+}
+
+// 创建 a+b problem 的错误提交
+func CreateWrongSubmission() problem.Submission {
+	submission := problem.Submission{}
+	submission.SetData(workflow.Gsubm, "source", []byte(APlusBWrongSourceCpp))
+	submission.SetData(workflow.Gsubm, "option", (&data.CompileConf{
+		Lang: yutils.Lcpp11,
 	}).Serialize())
 	return submission
 }
+
+// 创建 a+b problem 的 hack 数据
+func CreateHack(input string) problem.Submission {
+	hack := problem.Submission{}
+	hack.SetData(workflow.Gtests, "input", []byte(input))
+	return hack
+}
@@ -0,0 +1,209 @@
+package data
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+)
+
+var ErrInvalidSum = errors.New("invalid blob checksum")
+
+var sumPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// 池中文件的文件名，见 [BlobStore.blob]
+var blobPattern = regexp.MustCompile("^[0-9a-f]{64}(-[0-7]{3})?$")
+
+// 池中文件的默认权限
+const blobMode os.FileMode = 0644
+
+// 基于内容寻址（SHA-256）的文件池，相同的内容只保存一份
+//
+// 池中的文件通过硬链接（或者 reflink）分发出去，文件系统不支持时退化为复制。
+// 由于硬链接共享同一份数据，分发出去的文件不应当被原地修改，[File.Set] 会
+// 自动断开硬链接。权限不同的文件不会共享，因此 Dedup 不会改变文件的权限。
+type BlobStore struct {
+	dir string
+}
+
+// 内容为 sum，权限为 mode 的文件的路径
+//
+// 权限为 blobMode 时就是 sum 本身，否则加上权限的后缀，例如 "<sum>-755"
+func (r *BlobStore) blob(sum string, mode os.FileMode) string {
+	if mode.Perm() == blobMode {
+		return path.Join(r.dir, sum)
+	}
+	return path.Join(r.dir, fmt.Sprintf("%s-%03o", sum, mode.Perm()))
+}
+
+// 存入 store 的内容，返回其校验值
+func (r *BlobStore) Put(store Store) (string, error) {
+	return r.put(store, blobMode)
+}
+
+// 先流式地计算校验值，池中没有对应的文件时才写入
+func (r *BlobStore) put(store Store, mode os.FileMode) (string, error) {
+	hash := sha256.New()
+	if _, err := Copy(hash, store); err != nil {
+		return "", err
+	}
+	sum := fmt.Sprintf("%x", hash.Sum(nil))
+	if _, err := os.Stat(r.blob(sum, mode)); err == nil {
+		return sum, nil
+	}
+
+	tmp := path.Join(r.dir, ".tmp-"+utils.RandomString(10))
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	hash.Reset()
+	_, err = Copy(io.MultiWriter(file, hash), store)
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	// 内容在两次读取之间发生了变化
+	if fmt.Sprintf("%x", hash.Sum(nil)) != sum {
+		return "", ErrInvalidSum
+	}
+	// 不受 umask 影响
+	if err := os.Chmod(tmp, mode.Perm()); err != nil {
+		return "", err
+	}
+	return sum, os.Rename(tmp, r.blob(sum, mode))
+}
+
+// 是否存在对应的内容
+func (r *BlobStore) Exist(sum string) bool {
+	if !sumPattern.MatchString(sum) {
+		return false
+	}
+	_, err := os.Stat(r.blob(sum, blobMode))
+	return err == nil
+}
+
+// 将对应的内容放到 name 处
+func (r *BlobStore) Checkout(sum string, name string) (*File, error) {
+	if !r.Exist(sum) {
+		return nil, ErrInvalidSum
+	}
+	if err := share(r.blob(sum, blobMode), name); err != nil {
+		return nil, err
+	}
+	return NewFileFile(name), nil
+}
+
+// 将文件 name 存入池中，并将其替换为池中（相同权限的）文件的链接
+//
+// 文件系统不支持链接时文件保持不变，其他错误（例如池与文件不在同一个文件系统
+// 中）会被返回
+func (r *BlobStore) Dedup(name string) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	sum, err := r.put(NewFileFile(name), info.Mode())
+	if err != nil {
+		return err
+	}
+	blob := r.blob(sum, info.Mode())
+	if sameFile(blob, name) {
+		return nil
+	}
+	tmp := path.Join(filepath.Dir(name), ".tmp-"+utils.RandomString(10))
+	if err := link(blob, tmp); err != nil {
+		if linkUnsupported(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// 删除池中不再被引用（没有其他硬链接）的文件，返回删除的数量
+//
+// 通过 reflink 或者复制分发的文件不计入引用
+func (r *BlobStore) Prune() (int, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, entry := range entries {
+		if !blobPattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if nlink, ok := linkCount(info); ok && nlink == 1 {
+			if os.Remove(path.Join(r.dir, entry.Name())) == nil {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// create dir if necessary
+func NewBlobStore(dir string) (*BlobStore, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	return &BlobStore{dir: dir}, nil
+}
+
+// 依次尝试硬链接、reflink 和复制
+func share(src, dst string) error {
+	if err := link(src, dst); err == nil {
+		return nil
+	}
+	_, err := utils.CopyFile(src, dst)
+	return err
+}
+
+// 硬链接，不支持时尝试 reflink，都失败时返回硬链接的错误
+func link(src, dst string) error {
+	err := os.Link(src, dst)
+	if err == nil {
+		return nil
+	}
+	if reflink(src, dst) == nil {
+		return nil
+	}
+	return err
+}
+
+func sameFile(a, b string) bool {
+	ainfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	binfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ainfo, binfo)
+}
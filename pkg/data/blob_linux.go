@@ -0,0 +1,47 @@
+package data
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// ioctl FICLONE，见 ioctl_ficlone(2)
+const ficlone = 0x40049409
+
+// 写时复制的克隆（btrfs, xfs 等文件系统支持）
+func reflink(src, dst string) error {
+	sfile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sfile.Close()
+	info, err := sfile.Stat()
+	if err != nil {
+		return err
+	}
+	dfile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode())
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dfile.Fd(), ficlone, sfile.Fd())
+	dfile.Close()
+	if errno != 0 {
+		os.Remove(dst)
+		return errno
+	}
+	return nil
+}
+
+func linkCount(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true
+}
+
+// 文件系统不支持硬链接，此时 link(2) 返回 EPERM
+func linkUnsupported(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EOPNOTSUPP)
+}
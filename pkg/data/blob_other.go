@@ -0,0 +1,20 @@
+//go:build !linux
+
+package data
+
+import (
+	"errors"
+	"os"
+)
+
+func reflink(src, dst string) error {
+	return errors.New("reflink not supported")
+}
+
+func linkCount(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func linkUnsupported(err error) bool {
+	return false
+}
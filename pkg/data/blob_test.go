@@ -0,0 +1,100 @@
+package data_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/super-yaoj/yaoj-core/pkg/data"
+)
+
+func TestBlobStore(t *testing.T) {
+	pool, err := data.NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := pool.Put(data.NewInMemory([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum2, _ := pool.Put(data.NewInMemory([]byte("hello"))); sum2 != sum {
+		t.Fatal("same content different sum", sum, sum2)
+	}
+	if _, err := pool.Checkout(strings.Repeat("0", 64), path.Join(t.TempDir(), "x")); err == nil {
+		t.Fatal("checkout missing blob")
+	}
+
+	dir := t.TempDir()
+	a := data.NewFile(path.Join(dir, "a"), []byte("hello"))
+	b := data.NewFile(path.Join(dir, "b"), []byte("hello"))
+	if err := pool.Dedup(a.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Dedup(b.Path()); err != nil {
+		t.Fatal(err)
+	}
+	ainfo, _ := os.Stat(a.Path())
+	binfo, _ := os.Stat(b.Path())
+	if !os.SameFile(ainfo, binfo) {
+		t.Fatal("files not shared")
+	}
+	c, err := pool.Checkout(sum, path.Join(dir, "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writing to a shared file must not affect others
+	if err := a.Set([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if ctnt, _ := b.Get(); string(ctnt) != "hello" {
+		t.Fatal("shared file modified", string(ctnt))
+	}
+	if ctnt, _ := c.Get(); string(ctnt) != "hello" {
+		t.Fatal("shared file modified", string(ctnt))
+	}
+
+	// blobs are kept while referenced
+	if n, _ := pool.Prune(); n != 0 {
+		t.Fatal("referenced blob pruned")
+	}
+	os.Remove(b.Path())
+	os.Remove(c.Path())
+	// b (0755) and c (0644) are kept in different blobs
+	if n, _ := pool.Prune(); n != 2 || pool.Exist(sum) {
+		t.Fatal("unreferenced blob not pruned", n)
+	}
+
+	// dedup keeps the mode of files
+	modes := map[string]os.FileMode{"exe": 0755, "plain": 0644, "private": 0600}
+	infos := map[string]os.FileInfo{}
+	for name, mode := range modes {
+		name := path.Join(dir, name)
+		if err := os.WriteFile(name, []byte("hello"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(name, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := pool.Dedup(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, mode := range modes {
+		info, err := os.Stat(path.Join(dir, name))
+		if err != nil || info.Mode().Perm() != mode {
+			t.Fatal("mode changed", name, info.Mode(), err)
+		}
+		for other, oinfo := range infos {
+			if os.SameFile(info, oinfo) {
+				t.Fatal("files with different modes shared", name, other)
+			}
+		}
+		infos[name] = info
+	}
+
+	if err := pool.Dedup(path.Join(dir, "missing")); err == nil {
+		t.Fatal("dedup missing file")
+	}
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+)
+
+// 压缩算法
+type Codec interface {
+	// 压缩文件的扩展名，例如 ".gz"
+	Ext() string
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Ext() string {
+	return ".gz"
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCodec) NewWriter(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}
+
+// gzip 压缩（标准库）
+var Gzip Codec = gzipCodec{}
+
+// 所有支持的压缩算法
+//
+// zstd 等算法需要引入额外的依赖，目前只支持标准库中的 gzip
+var codecs = []Codec{Gzip}
+
+// 根据文件的扩展名判断压缩算法，不是压缩文件时返回 nil
+func CodecOf(name string) Codec {
+	for _, codec := range codecs {
+		if strings.HasSuffix(name, codec.Ext()) {
+			return codec
+		}
+	}
+	return nil
+}
+
+// 以压缩形式存储在文件中的数据
+//
+// Get/Open 时直接流式地解压。只有在需要真实路径（Path/File）时才会解压到
+// 同一文件夹下的隐藏文件 ".plain-<name>" 中（使用 [Compressed.In] 时则解压到
+// 指定的文件夹中），之后的修改会使其失效。
+type Compressed struct {
+	// 压缩文件的路径
+	filepath string
+	codec    Codec
+	// 解压到的文件夹，为空表示压缩文件所在的文件夹
+	dir string
+	// 解压后的文件的路径，为空表示尚未解压
+	plain string
+	// 解压后的文件的权限
+	mode os.FileMode
+}
+
+// 解压后的文件的路径
+func (r *Compressed) plainPath() string {
+	dir, name := path.Split(r.filepath)
+	name = ".plain-" + strings.TrimSuffix(name, r.codec.Ext())
+	if r.dir != "" {
+		// 不同文件夹中的压缩文件可能同名
+		return path.Join(r.dir, name+"-"+utils.RandomString(10))
+	}
+	return path.Join(dir, name)
+}
+
+// 与 r 共享压缩文件，但是解压到 dir 中的副本
+//
+// 评测时题目数据通过它解压到工作目录中，从而随工作目录一起删除，不会在题目的
+// 文件夹中留下解压后的文件。
+func (r *Compressed) In(dir string) *Compressed {
+	return &Compressed{
+		filepath: r.filepath,
+		codec:    r.codec,
+		dir:      dir,
+		mode:     r.mode,
+	}
+}
+
+// 解压到文件中，返回其路径
+func (r *Compressed) Path() string {
+	if r.plain == "" {
+		name := r.plainPath()
+		if err := writeFile(name, r, r.mode); err != nil {
+			// 与 File 一致，Path 不报错，之后对该路径的读取会失败
+			return name
+		}
+		r.plain = name
+	}
+	return r.plain
+}
+
+func (r *Compressed) File() (*os.File, error) {
+	return os.Open(r.Path())
+}
+
+func (r *Compressed) SetMode(mode os.FileMode) error {
+	r.mode = mode
+	if r.plain != "" {
+		return os.Chmod(r.plain, mode)
+	}
+	return nil
+}
+
+type compressedReader struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (r *compressedReader) Close() error {
+	r.ReadCloser.Close()
+	return r.file.Close()
+}
+
+func (r *Compressed) Open() (io.ReadCloser, error) {
+	file, err := os.Open(r.filepath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := r.codec.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &compressedReader{ReadCloser: reader, file: file}, nil
+}
+
+// 解压后的大小
+func (r *Compressed) Size() (int64, error) {
+	if r.plain != "" {
+		stat, err := os.Stat(r.plain)
+		if err != nil {
+			return 0, err
+		}
+		return stat.Size(), nil
+	}
+	return Copy(io.Discard, r)
+}
+
+func (r *Compressed) Get() ([]byte, error) {
+	src, err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+func (r *Compressed) Set(data []byte) error {
+	// 断开可能存在的硬链接
+	os.Remove(r.filepath)
+	file, err := os.OpenFile(r.filepath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := r.codec.NewWriter(file)
+	if _, err := w.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	if r.plain != "" {
+		os.Remove(r.plain)
+		r.plain = ""
+	}
+	return file.Close()
+}
+
+func (r *Compressed) DupFile(name string, mode os.FileMode) error {
+	return writeFile(name, r, mode)
+}
+
+var _ FileStore = (*Compressed)(nil)
+
+// name 为已经存在的压缩文件
+func NewCompressed(name string, codec Codec) *Compressed {
+	return &Compressed{
+		filepath: name,
+		codec:    codec,
+		mode:     0644,
+	}
+}
+
+// 将 store 的内容压缩后写入 name
+//
+// name 已经存在时会先断开可能存在的硬链接
+func NewCompressedStore(name string, store Store, codec Codec) (*Compressed, error) {
+	src, err := store.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := codec.NewWriter(file)
+	if _, err = io.Copy(w, src); err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	return NewCompressed(name, codec), nil
+}
@@ -1,6 +1,9 @@
 package data
 
-import "os"
+import (
+	"io"
+	"os"
+)
 
 // Setter 是指可以修改内容的数据
 type Setter interface {
@@ -13,6 +16,14 @@ type Getter interface {
 	Get() (data []byte, err error)
 }
 
+// Opener 是指可以流式读取的数据，适用于较大的数据
+type Opener interface {
+	// idempotent，调用者负责关闭
+	Open() (io.ReadCloser, error)
+	// 内容的大小（byte）
+	Size() (int64, error)
+}
+
 // Filer 是指具有文件形式的数据
 type Filer interface {
 	// idempotent
@@ -34,6 +45,7 @@ type ModeSetter interface {
 type Store interface {
 	Setter
 	Getter
+	Opener
 }
 
 // 可以存取数据，导出为文件的数据
@@ -45,3 +57,13 @@ type FileStore interface {
 	// copy to file
 	DupFile(name string, mode os.FileMode) error
 }
+
+// 将 store 的内容流式地写入 dst，返回写入的字节数
+func Copy(dst io.Writer, store Store) (int64, error) {
+	src, err := store.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return io.Copy(dst, src)
+}
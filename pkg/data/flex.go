@@ -29,7 +29,32 @@ func (r *File) Get() (data []byte, err error) {
 	return os.ReadFile(r.filepath)
 }
 
+func (r *File) Open() (io.ReadCloser, error) {
+	return os.Open(r.filepath)
+}
+
+func (r *File) Size() (int64, error) {
+	stat, err := os.Stat(r.filepath)
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// 如果该文件存在其他硬链接（例如来自 [BlobStore]），会先断开链接再写入，
+// 不会影响共享同一份数据的其他文件
 func (r *File) Set(data []byte) error {
+	if info, err := os.Stat(r.filepath); err == nil {
+		if nlink, ok := linkCount(info); ok && nlink > 1 {
+			if err := os.Remove(r.filepath); err != nil {
+				return err
+			}
+			if err := os.WriteFile(r.filepath, data, info.Mode()); err != nil {
+				return err
+			}
+			return os.Chmod(r.filepath, info.Mode())
+		}
+	}
 	return os.WriteFile(r.filepath, data, os.ModePerm)
 }
 
@@ -68,11 +93,20 @@ func (r *File) ChangePath(name string) error {
 }
 
 func (r *File) DupFile(name string, mode os.FileMode) error {
-	data, err := r.Get()
+	return writeFile(name, r, mode)
+}
+
+// 将 store 的内容流式地写入文件
+func writeFile(name string, store Store, mode os.FileMode) error {
+	dest, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(name, data, mode)
+	if _, err := Copy(dest, store); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
 }
 
 var _ FileStore = (*File)(nil)
@@ -91,11 +125,10 @@ func NewFile(name string, data []byte) *File {
 
 // 必须指定一个有效的文件路径，创建一个 File
 func NewFileStore(name string, store Store) (*File, error) {
-	data, err := store.Get()
-	if err != nil {
+	if err := writeFile(name, store, os.ModePerm); err != nil {
 		return nil, err
 	}
-	return NewFile(name, data), nil
+	return NewFileFile(name), nil
 }
 
 // File store with file initialized
@@ -55,3 +55,30 @@ func TestFile(t *testing.T) {
 		t.Fatal("dup file error")
 	}
 }
+
+func TestStream(t *testing.T) {
+	ctnt := strings.Repeat("yaoj", 1024)
+	for name, store := range map[string]data.Store{
+		"File":     data.NewFile(path.Join(t.TempDir(), "File"), []byte(ctnt)),
+		"InMemory": data.NewInMemory([]byte(ctnt)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			size, err := store.Size()
+			if err != nil || size != int64(len(ctnt)) {
+				t.Fatal("invalid size", size, err)
+			}
+			var buf strings.Builder
+			n, err := data.Copy(&buf, store)
+			if err != nil || n != size || buf.String() != ctnt {
+				t.Fatal("invalid content", n, err)
+			}
+			file, err := data.NewFileStore(path.Join(t.TempDir(), "file"), store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := file.Get(); string(got) != ctnt {
+				t.Fatal("invalid file store")
+			}
+		})
+	}
+}
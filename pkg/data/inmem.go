@@ -1,5 +1,10 @@
 package data
 
+import (
+	"bytes"
+	"io"
+)
+
 // in-memory store
 type InMemory struct {
 	data []byte
@@ -13,6 +18,14 @@ func (r *InMemory) Get() ([]byte, error) {
 	return r.data, nil
 }
 
+func (r *InMemory) Open() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(r.data)), nil
+}
+
+func (r *InMemory) Size() (int64, error) {
+	return int64(len(r.data)), nil
+}
+
 var _ Store = (*InMemory)(nil)
 
 func NewInMemory(data []byte) *InMemory {
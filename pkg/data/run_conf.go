@@ -25,3 +25,17 @@ func (r *RunConf) Deserialize(data []byte) error {
 func (r *RunConf) IsFileIO() bool {
 	return r.Inf != "" && r.Ouf != ""
 }
+
+// 合并多层的运行配置，优先级从低到高
+//
+// 每层都是序列化后的（可以只包含部分字段的）RunConf，例如 {"CpuTime": 2000}，
+// 出现的字段会覆盖低层的对应字段。
+func MergeRunConf(layers ...[]byte) (*RunConf, error) {
+	res := &RunConf{}
+	for _, layer := range layers {
+		if err := res.Deserialize(layer); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
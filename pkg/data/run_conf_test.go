@@ -0,0 +1,21 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/super-yaoj/yaoj-core/pkg/data"
+)
+
+func TestMergeRunConf(t *testing.T) {
+	base := (&data.RunConf{CpuTime: 1000, RealMem: 256, Inf: "a.in", Ouf: "a.out"}).Serialize()
+	conf, err := data.MergeRunConf(base, []byte(`{"CpuTime": 2000}`), []byte(`{"Inf": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.CpuTime != 2000 || conf.RealMem != 256 || conf.Inf != "" || conf.Ouf != "a.out" {
+		t.Fatal("invalid merged conf", conf)
+	}
+	if _, err := data.MergeRunConf(base, []byte("{")); err == nil {
+		t.Fatal("invalid layer accepted")
+	}
+}
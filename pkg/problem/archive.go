@@ -0,0 +1,196 @@
+package problem
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// 解压题目数据时的限制，用于防止 zip 炸弹，0 表示不限制
+type ExtractLimits struct {
+	// 文件数量上限
+	MaxFiles int
+	// 单个文件解压后的大小上限（byte）
+	MaxFileSize int64
+	// 所有文件解压后的总大小上限（byte）
+	MaxTotalSize int64
+}
+
+var DefaultExtractLimits = ExtractLimits{
+	MaxFiles:     100000,
+	MaxFileSize:  1 << 30,
+	MaxTotalSize: 8 << 30,
+}
+
+// 检查压缩包中的所有文件，文件大小以文件头中记录的为准，实际解压时会再次检查
+func (r ExtractLimits) check(files []*zip.File) error {
+	if r.MaxFiles > 0 && len(files) > r.MaxFiles {
+		return yerrors.Annotated("count", len(files), ErrTooManyFiles)
+	}
+	var total uint64
+	for _, f := range files {
+		if !fs.ValidPath(f.Name) && !fs.ValidPath(strings.TrimSuffix(f.Name, "/")) {
+			return yerrors.Annotated("name", f.Name, ErrInvalidPath)
+		}
+		if r.MaxFileSize > 0 && f.UncompressedSize64 > uint64(r.MaxFileSize) {
+			return yerrors.Annotated("name", f.Name, ErrFileTooLarge)
+		}
+		total += f.UncompressedSize64
+		if r.MaxTotalSize > 0 && total > uint64(r.MaxTotalSize) {
+			return yerrors.Annotated("size", total, ErrArchiveTooLarge)
+		}
+	}
+	return nil
+}
+
+// 读取时限制大小，防止文件头中记录的大小与实际不符
+func (r ExtractLimits) reader(name string, src io.Reader) io.Reader {
+	if r.MaxFileSize <= 0 {
+		return src
+	}
+	return &limitedReader{
+		r:    io.LimitReader(src, r.MaxFileSize+1),
+		n:    r.MaxFileSize,
+		name: name,
+	}
+}
+
+type limitedReader struct {
+	r    io.Reader
+	n    int64
+	name string
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n -= int64(n)
+	if r.n < 0 {
+		return n, yerrors.Annotated("name", r.name, ErrFileTooLarge)
+	}
+	return n, err
+}
+
+// 题目数据的压缩包，DirRecord 的数据在修改或者需要文件时才会解压
+type archive struct {
+	*zip.ReadCloser
+	limits ExtractLimits
+	// 已经解压到题目文件夹中的 DirRecord（以 Dir 标识）
+	extracted map[string]bool
+}
+
+func (r *archive) readFile(name string) ([]byte, error) {
+	file, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(r.limits.reader(name, file))
+}
+
+// 将压缩包中的文件 name 解压到 destination 中
+func (r *archive) extractFile(name string, destination string) error {
+	file, err := r.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(destination, name)
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+	dest, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dest, r.limits.reader(name, file)); err != nil {
+		dest.Close()
+		os.Remove(filePath)
+		return err
+	}
+	return dest.Close()
+}
+
+// 打开压缩包并检查限制
+func openArchive(name string, limits ExtractLimits) (*archive, error) {
+	reader, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := limits.check(reader.File); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return &archive{
+		ReadCloser: reader,
+		limits:     limits,
+		extracted:  map[string]bool{},
+	}, nil
+}
+
+// 加载题目压缩包，但不解压
+//
+// DirRecord 的数据直接从压缩包中读取，在被修改或者转化为读入数据时才会解压
+// 到 dir 中。压缩包在 Finalize 之前需要一直存在。
+func LoadZip(name string, dir string, limits ExtractLimits) (*Data, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	arch, err := openArchive(name, limits)
+	if err != nil {
+		return nil, err
+	}
+	res, err := loadData(dir, func() ([]byte, error) {
+		return arch.readFile("problem.json")
+	})
+	if err != nil {
+		arch.Close()
+		return nil, err
+	}
+	res.archive = arch
+	return res, nil
+}
+
+// 是否直接从压缩包中读取
+func (r *DirRecord) archived() bool {
+	return r.prob.archive != nil && !r.prob.archive.extracted[path.Clean(r.Dir)]
+}
+
+// 将数据从压缩包中解压出来
+func (r *DirRecord) extract() error {
+	if !r.archived() {
+		return nil
+	}
+	entries, err := fs.ReadDir(r.prob.archive, path.Clean(r.Dir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		err := r.prob.archive.extractFile(path.Join(path.Clean(r.Dir), entry.Name()), r.prob.dir)
+		if err != nil {
+			return err
+		}
+	}
+	r.prob.archive.extracted[path.Clean(r.Dir)] = true
+	if r.prob.pool != nil {
+		return r.Dedup(r.prob.pool)
+	}
+	return nil
+}
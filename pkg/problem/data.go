@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"os"
 	"path"
+	"strings"
 
+	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/log"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow"
 )
@@ -13,6 +15,9 @@ import (
 //
 // 对于静态的题目数据，为了方便人类阅读和人为修改，所有的数据都会存储在文件中
 type Data struct {
+	// problem.json 格式的版本，见 [Version]
+	Version int `json:"version"`
+
 	// 题目满分 Usually 100.
 	// Full score can be used to determine the point of testcase
 	Fullscore float64 `json:"fullscore"`
@@ -56,6 +61,10 @@ type Data struct {
 
 	// 数据文件存放的文件夹 absolute dir
 	dir string
+	// 通过 LoadZip 加载时的压缩包，否则为 nil
+	archive *archive
+	// 数据文件存入的文件池，见 Dedup
+	pool *data.BlobStore
 
 	lg *log.Entry
 }
@@ -64,6 +73,9 @@ type Data struct {
 //
 // 在题目文件夹下建立的 problem.json 包含所有元信息
 func (r *Data) DumpFile(dest string) error {
+	if err := r.extractAll(); err != nil {
+		return err
+	}
 	data, err := json.Marshal(r)
 	if err != nil {
 		return err
@@ -72,27 +84,74 @@ func (r *Data) DumpFile(dest string) error {
 	if err != nil {
 		return err
 	}
-	err = zipDir(r.dir, dest, r.lg)
+	err = zipDir(r.dir, dest, r.lg, nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// 将整个题目打包，其中测试数据（Pretest, Extra, Data）以 gzip 压缩的形式
+// 存储，加载后也保持压缩，评测时按需解压
+func (r *Data) DumpFileCompressed(dest string) error {
+	if err := r.extractAll(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path.Join(r.dir, "problem.json"), data, 0644)
+	if err != nil {
+		return err
+	}
+	var dirs []string
+	for _, group := range []*TestdataGroup{r.Pretest, r.Extra, r.Data} {
+		if group == nil {
+			continue
+		}
+		dirs = append(dirs, path.Clean(group.Dir)+"/")
+	}
+	return zipDir(r.dir, dest, r.lg, func(zippath string) bool {
+		for _, dir := range dirs {
+			if strings.HasPrefix(zippath, dir) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
 // load problem archive to dir
+//
+// 解压时使用 [DefaultExtractLimits]
 func LoadFileTo(name string, dir string) (*Data, error) {
-	err := unzipSource(name, dir)
+	err := unzipSource(name, dir, DefaultExtractLimits)
 	if err != nil {
 		return nil, err
 	}
-	conf, err := os.ReadFile(path.Join(dir, "problem.json"))
+	return loadData(dir, func() ([]byte, error) {
+		return os.ReadFile(path.Join(dir, "problem.json"))
+	})
+}
+
+// 根据 problem.json 的内容创建题目
+//
+// 旧版本的内容会先升级到当前版本
+func loadData(dir string, conf func() ([]byte, error)) (*Data, error) {
+	ctnt, err := conf()
+	if err != nil {
+		return nil, err
+	}
+	ctnt, err = migrate(ctnt)
 	if err != nil {
 		return nil, err
 	}
 	res := &Data{
 		dir: dir,
+		lg:  log.NewTerminal().WithField("problem", dir),
 	}
-	err = json.Unmarshal(conf, res)
+	err = json.Unmarshal(ctnt, res)
 	if err != nil {
 		return nil, err
 	}
@@ -100,19 +159,77 @@ func LoadFileTo(name string, dir string) (*Data, error) {
 	return res, nil
 }
 
+// 缺失的记录会保留为 nil，由 Validate 报告
 func (r *Data) initProb() {
-	r.Attached.prob = r
-	r.Statement.prob = r
-	r.Tutorial.prob = r
-	r.Static.prob = r
-	r.Pretest.initProb(r)
-	r.Extra.initProb(r)
-	r.Data.initProb(r)
+	for _, record := range []*DirRecord{r.Attached, r.Statement, r.Tutorial, r.Static} {
+		if record != nil {
+			record.prob = r
+		}
+	}
+	for _, group := range []*TestdataGroup{r.Pretest, r.Extra, r.Data} {
+		if group != nil {
+			group.initProb(r)
+		}
+	}
+}
+
+// 所有的 DirRecord
+func (r *Data) records() []*DirRecord {
+	var res []*DirRecord
+	add := func(records ...*DirRecord) {
+		for _, record := range records {
+			if record != nil {
+				res = append(res, record)
+			}
+		}
+	}
+	add(r.Attached, r.Statement, r.Tutorial, r.Static)
+	for _, group := range []*TestdataGroup{r.Pretest, r.Extra, r.Data} {
+		if group == nil {
+			continue
+		}
+		add(group.Record)
+		add(group.Testcases...)
+		for _, subtask := range group.Subtasks {
+			if subtask != nil {
+				add(subtask.Record)
+				add(subtask.Testcases...)
+			}
+		}
+	}
+	return res
+}
+
+// 将压缩包中的数据全部解压
+func (r *Data) extractAll() error {
+	for _, record := range r.records() {
+		if err := record.extract(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 将题目的所有数据文件存入文件池，例如 Pretest 与 Data 中相同的测试点只会
+// 保存一份
+//
+// 之后从压缩包中解压出的数据也会存入文件池
+func (r *Data) Dedup(pool *data.BlobStore) error {
+	r.pool = pool
+	for _, record := range r.records() {
+		if err := record.Dedup(pool); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // remove problem (dir)
 func (r *Data) Finalize() error {
 	r.lg.Infof("finalize.")
+	if r.archive != nil {
+		r.archive.Close()
+	}
 	return os.RemoveAll(r.dir)
 }
 
@@ -132,6 +249,7 @@ func New(dir string, logger *log.Entry) (*Data, error) {
 	}
 
 	res := &Data{
+		Version:    Version,
 		Fullscore:  100, // default
 		Workflow:   workflow.New(),
 		Submission: make(SubmConf),
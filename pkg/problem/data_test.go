@@ -1,11 +1,18 @@
 package problem_test
 
 import (
+	"os"
 	"path"
+	"strings"
 	"testing"
 
+	"github.com/super-yaoj/yaoj-core/internal/tests"
+	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/log"
 	"github.com/super-yaoj/yaoj-core/pkg/problem"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow/preset"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 func TestData(t *testing.T) {
@@ -94,5 +101,283 @@ func TestData(t *testing.T) {
 		t.Fatal("invalid err", err)
 	}
 	t.Log(err)
+
+	// compressed testdata
+	cdst := path.Join(t.TempDir(), "compressed.zip")
+	if err := prob.DumpFileCompressed(cdst); err != nil {
+		t.Fatal(err)
+	}
+	prob3, err := problem.LoadFileTo(cdst, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcase := prob3.Data.Subtasks[0].Testcases[0]
+	inbound, err := testcase.InboundGroup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := inbound["output"].(*data.Compressed); !ok {
+		t.Fatalf("testdata not compressed: %T", inbound["output"])
+	}
+	if ctnt, err := testcase.GetData("output"); err != nil || string(ctnt) != "7" {
+		t.Fatal("output changed", string(ctnt), err)
+	}
+	// materialize only when a path is needed
+	if ctnt, err := os.ReadFile(inbound["input"].Path()); err != nil || string(ctnt) != "3 4" {
+		t.Fatal("input changed", string(ctnt), err)
+	}
+	// materialize into a work dir, leaving the problem dir untouched
+	workdir := t.TempDir()
+	plain := inbound["output"].(*data.Compressed).In(workdir).Path()
+	if path.Dir(plain) != workdir {
+		t.Fatal("not materialized in work dir", plain)
+	}
+	if ctnt, err := os.ReadFile(plain); err != nil || string(ctnt) != "7" {
+		t.Fatal("output changed", string(ctnt), err)
+	}
+	testcase.Range(func(field, name string) {
+		if field != "input" && field != "output" {
+			t.Fatalf("unknown field: %s, %s", field, name)
+		}
+	})
+	if stmt, _ := prob3.Statement.GetData("zh"); string(stmt) != stmt_str {
+		t.Fatal("statement changed", string(stmt))
+	}
+	// compress a single field
+	if err := prob3.Statement.Compress("zh"); err != nil {
+		t.Fatal(err)
+	}
+	if stmt, _ := prob3.Statement.GetData("zh"); string(stmt) != stmt_str {
+		t.Fatal("statement changed", string(stmt))
+	}
+
+	// lazy loading
+	for _, name := range []string{dst, cdst} {
+		pdir := t.TempDir()
+		prob4, err := problem.LoadZip(name, pdir, problem.DefaultExtractLimits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testcase := prob4.Data.Subtasks[0].Testcases[0]
+		if ctnt, err := testcase.GetData("output"); err != nil || string(ctnt) != "7" {
+			t.Fatal("output changed", string(ctnt), err)
+		}
+		if entries, _ := os.ReadDir(pdir); len(entries) != 0 {
+			t.Fatal("extracted before needed", entries)
+		}
+		inbound, err := testcase.InboundGroup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctnt, err := inbound["input"].Get(); err != nil || string(ctnt) != "3 4" {
+			t.Fatal("input changed", string(ctnt), err)
+		}
+		if err := prob4.Statement.SetData("en", []byte("a+b")); err != nil {
+			t.Fatal(err)
+		}
+		if stmt, _ := prob4.Statement.GetData("zh"); string(stmt) != stmt_str {
+			t.Fatal("statement changed", string(stmt))
+		}
+		prob4.Finalize()
+	}
+
+	// extraction limits
+	for _, c := range []struct {
+		limits problem.ExtractLimits
+		err    error
+	}{
+		{problem.ExtractLimits{MaxFiles: 1}, problem.ErrTooManyFiles},
+		{problem.ExtractLimits{MaxFileSize: 1}, problem.ErrFileTooLarge},
+		{problem.ExtractLimits{MaxTotalSize: 2}, problem.ErrArchiveTooLarge},
+	} {
+		if _, err := problem.LoadZip(dst, t.TempDir(), c.limits); !yerrors.Is(err, c.err) {
+			t.Fatal("invalid error", err)
+		}
+	}
+
+	// version
+	prob.Version, prob.Workflow.Version = 0, 0
+	vdst := path.Join(t.TempDir(), "old.zip")
+	if err := prob.DumpFile(vdst); err != nil {
+		t.Fatal(err)
+	}
+	prob5, err := problem.LoadFileTo(vdst, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prob5.Version != problem.Version || prob5.Workflow.Version != workflow.Version {
+		t.Fatal("not migrated", prob5.Version, prob5.Workflow.Version)
+	}
+	prob.Version = problem.Version + 1
+	if err := prob.DumpFile(vdst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := problem.LoadFileTo(vdst, t.TempDir()); !yerrors.Is(err, problem.ErrUnsupportedVersion) {
+		t.Fatal("invalid error", err)
+	}
+
+	// unset testdata groups
+	prob3.Extra = nil
+	if err := prob3.DumpFileCompressed(cdst); err != nil {
+		t.Fatal(err)
+	}
+	prob6, err := problem.LoadFileTo(cdst, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prob6.Extra != nil {
+		t.Fatal("extra group restored", prob6.Extra)
+	}
+	if ctnt, err := prob6.Data.Subtasks[0].Testcases[0].GetData("output"); err != nil || string(ctnt) != "7" {
+		t.Fatal("output changed", string(ctnt), err)
+	}
 	// pp.Println(prob2)
 }
+
+func TestDedup(t *testing.T) {
+	prob, err := tests.CreateProblem(t.TempDir(), log.NewTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := data.NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prob.Dedup(pool); err != nil {
+		t.Fatal(err)
+	}
+	// pretest 与 data 的第一个测试点相同，去重后共享同一个文件
+	edited, other := prob.Pretest.Testcases[0], prob.Data.Subtasks[0].Testcases[0]
+	source := path.Join(t.TempDir(), "source")
+	if err := os.WriteFile(source, []byte("9"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := edited.SetData("input", []byte("7 8")); err != nil {
+		t.Fatal(err)
+	}
+	if err := edited.SetSource("output", source); err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{"input": "1 2", "output": "3"} {
+		if ctnt, err := other.GetData(field); err != nil || string(ctnt) != want {
+			t.Fatal("shared data modified", field, string(ctnt), err)
+		}
+	}
+
+}
+
+func TestValidate(t *testing.T) {
+	prob, err := tests.CreateProblem(t.TempDir(), log.NewTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags := prob.Validate(); diags.HasError() {
+		t.Fatal("valid problem reported\n", diags)
+	}
+
+	prob.Static.Delete("checker")
+	prob.Pretest.Testcases[1].Delete("output")
+	prob.Data.Subtasks[0].Fullscore = 10
+	prob.Extra.Testcases = []*problem.TestcaseData{}
+	prob.Extra.Subtasks = []*problem.SubtaskData{}
+	prob.HackIOMap = workflow.Outbounds{"output": {Name: "run", Label: "unknown"}}
+
+	diags := prob.Validate()
+	t.Log("\n", diags)
+	if !diags.HasError() {
+		t.Fatal("invalid problem not reported")
+	}
+	for _, path := range []string{
+		"static",
+		"pretest.testcases[1]",
+		"data",
+		"extra",
+		"hack_map.output",
+	} {
+		found := false
+		for _, d := range diags {
+			if d.Path == path && d.Severity == problem.Error {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatal("diagnostic missing", path)
+		}
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	prob, err := tests.CreateProblem(t.TempDir(), log.NewTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := preset.Traditional.Text()
+	text = strings.Replace(text, "static.checker", "subtask.checker", 1)
+	text = strings.Replace(text, "static.runner_config", "testset.runner_config", 1)
+	prob.Workflow, err = workflow.ParseText([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prob.Workflow.Inbound["unknown"] = workflow.InboundFields{}
+	prob.Data.Subtasks[0].Record.SetData("checker", []byte(tests.NcmpSource))
+
+	diags := prob.Validate()
+	t.Log("\n", diags)
+	for _, path := range []string{
+		"workflow.inbound.unknown",
+		"pretest",
+		"data.record",
+		"data.subtasks[1].record",
+		"hack_config",
+	} {
+		found := false
+		for _, d := range diags {
+			if d.Path == path && d.Severity == problem.Error {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatal("diagnostic missing", path)
+		}
+	}
+	for _, d := range diags {
+		if d.Path == "data.subtasks[0].record" {
+			t.Fatal("valid subtask reported", d)
+		}
+	}
+
+	// 加载后保留子任务与数据组的数据
+	prob.Data.Record.SetData("runner_config", []byte("conf"))
+	dst := path.Join(t.TempDir(), "groups.zip")
+	if err := prob.DumpFile(dst); err != nil {
+		t.Fatal(err)
+	}
+	prob2, err := problem.LoadZip(dst, t.TempDir(), problem.DefaultExtractLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prob2.Finalize()
+	if ctnt, err := prob2.Data.Record.GetData("runner_config"); err != nil || string(ctnt) != "conf" {
+		t.Fatal("testset record changed", string(ctnt), err)
+	}
+	if group, err := prob2.Data.Subtasks[0].Record.InboundGroup(); err != nil || group["checker"] == nil {
+		t.Fatal("subtask record changed", err)
+	}
+	var nilRecord *problem.DirRecord
+	if group, err := nilRecord.InboundGroup(); err != nil || len(group) != 0 {
+		t.Fatal("invalid nil record", err)
+	}
+
+	// 解压失败时返回错误
+	prob3, err := problem.LoadZip(dst, t.TempDir(), problem.DefaultExtractLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prob3.Finalize()
+	if err := os.Truncate(dst, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prob3.Data.Subtasks[0].Record.InboundGroup(); err == nil {
+		t.Fatal("extraction error ignored")
+	}
+}
@@ -1,12 +1,17 @@
 package problem
 
 import (
+	"bytes"
+	"io"
+	"io/fs"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/utils"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 // 依赖文件夹的以文件相对路径的形式存储字段值
@@ -28,29 +33,121 @@ func (r *DirRecord) makeDir() error {
 	return nil
 }
 
+// 字段对应的文件路径
+func (r *DirRecord) fieldPath(field string) string {
+	return path.Join(r.prob.dir, r.Dir, field)
+}
+
+// 删除字段的压缩形式（以及解压出的临时文件）
+func (r *DirRecord) removeCompressed(field string) error {
+	name := r.fieldPath(field)
+	os.Remove(path.Join(path.Dir(name), ".plain-"+field))
+	err := os.Remove(name + data.Gzip.Ext())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// 删除字段的文件，之后重新创建
+//
+// 字段的文件可能是文件池中的硬链接（见 [DirRecord.Dedup]），原地写入会修改所有
+// 共享该文件的数据
+func (r *DirRecord) unlink(field string) error {
+	err := os.Remove(r.fieldPath(field))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
 // 删除某个字段及其数据
 func (r *DirRecord) Delete(field string) error {
 	delete(r.Lang, field)
-	return os.RemoveAll(path.Join(r.prob.dir, r.Dir, field))
+	if err := r.extract(); err != nil {
+		return err
+	}
+	if err := r.removeCompressed(field); err != nil {
+		return err
+	}
+	return os.RemoveAll(r.fieldPath(field))
 }
 func (r *DirRecord) SetData(field string, data []byte) error {
+	if err := r.extract(); err != nil {
+		return err
+	}
 	if err := r.makeDir(); err != nil {
 		return err
 	}
-	return os.WriteFile(path.Join(r.prob.dir, r.Dir, field), data, 0644)
+	if err := r.removeCompressed(field); err != nil {
+		return err
+	}
+	if err := r.unlink(field); err != nil {
+		return err
+	}
+	return os.WriteFile(r.fieldPath(field), data, 0644)
 }
+
+// 字段可能以压缩的形式存储，此时会自动解压
 func (r *DirRecord) GetData(field string) ([]byte, error) {
-	data, err := os.ReadFile(path.Join(r.prob.dir, r.Dir, field))
-	return data, err
+	if r.archived() {
+		return r.archiveData(field)
+	}
+	name := r.fieldPath(field) + data.Gzip.Ext()
+	if _, err := os.Stat(name); err == nil {
+		return data.NewCompressed(name, data.Gzip).Get()
+	}
+	return os.ReadFile(r.fieldPath(field))
 }
+
+// 直接从压缩包中读取字段
+func (r *DirRecord) archiveData(field string) ([]byte, error) {
+	name := path.Join(path.Clean(r.Dir), field)
+	ctnt, err := r.prob.archive.readFile(name + data.Gzip.Ext())
+	if os.IsNotExist(err) {
+		return r.prob.archive.readFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	reader, err := data.Gzip.NewReader(bytes.NewReader(ctnt))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// 解压后的大小同样受到限制
+	return io.ReadAll(r.prob.archive.limits.reader(name, reader))
+}
+
 func (r *DirRecord) SetSource(field string, source string) error {
+	if err := r.extract(); err != nil {
+		return err
+	}
 	if err := r.makeDir(); err != nil {
 		return err
 	}
-	_, err := utils.CopyFile(source, path.Join(r.prob.dir, r.Dir, field))
+	if err := r.removeCompressed(field); err != nil {
+		return err
+	}
+	if err := r.unlink(field); err != nil {
+		return err
+	}
+	_, err := utils.CopyFile(source, r.fieldPath(field))
 	return err
 }
 
+// 将字段改为以压缩（gzip）的形式存储，读取时会自动解压
+func (r *DirRecord) Compress(field string) error {
+	if err := r.extract(); err != nil {
+		return err
+	}
+	name := r.fieldPath(field)
+	if _, err := data.NewCompressedStore(name+data.Gzip.Ext(), data.NewFileFile(name), data.Gzip); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
 // 设置某个字段的内容的语言标签
 func (r *DirRecord) SetLang(field string, lang utils.LangTag) {
 	r.Lang[field] = lang
@@ -65,25 +162,67 @@ func (r *DirRecord) GetLang(field string) utils.LangTag {
 }
 
 // 遍历文件夹中的文件（name 是完整的文件名）
+//
+// 压缩存储的字段的 name 为压缩文件，隐藏文件（临时文件）会被忽略。对于尚未
+// 从压缩包中解压的数据，name 对应的文件在 InboundGroup 时才会创建。
 func (r *DirRecord) Range(visitor func(field string, name string)) {
-	entries, err := os.ReadDir(path.Join(r.prob.dir, r.Dir))
+	var entries []fs.DirEntry
+	var err error
+	if r.archived() {
+		entries, err = fs.ReadDir(r.prob.archive, path.Clean(r.Dir))
+	} else {
+		entries, err = os.ReadDir(path.Join(r.prob.dir, r.Dir))
+	}
 	if err != nil {
 		return
 	}
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 		name := entry.Name()
-		visitor(name, path.Join(r.prob.dir, r.Dir, name))
+		field := name
+		if codec := data.CodecOf(name); codec != nil {
+			field = strings.TrimSuffix(name, codec.Ext())
+		}
+		visitor(field, path.Join(r.prob.dir, r.Dir, name))
 	}
 }
 
+// 将所有文件存入文件池，相同内容的文件共享同一份数据
+//
+// 尚未从压缩包中解压的数据会被跳过
+func (r *DirRecord) Dedup(pool *data.BlobStore) error {
+	if r.archived() {
+		return nil
+	}
+	var err error
+	r.Range(func(field, name string) {
+		if err == nil {
+			err = pool.Dedup(name)
+		}
+	})
+	return err
+}
+
 // 转化为读入数据
-func (r *DirRecord) InboundGroup() workflow.InboundGroup {
+//
+// 数据会在此时从压缩包中解压，解压失败时返回错误。r 为 nil（例如旧版本的题目
+// 中没有子任务的数据）时返回空的读入数据。
+func (r *DirRecord) InboundGroup() (workflow.InboundGroup, error) {
 	res := workflow.InboundGroup{}
+	if r == nil {
+		return res, nil
+	}
+	if err := r.extract(); err != nil {
+		return nil, yerrors.Annotated("dir", r.Dir, yerrors.Situated("extract record", err))
+	}
 	r.Range(func(field, name string) {
-		res[field] = data.NewFileFile(name)
+		if codec := data.CodecOf(name); codec != nil {
+			res[field] = data.NewCompressed(name, codec)
+		} else {
+			res[field] = data.NewFileFile(name)
+		}
 	})
-	return res
+	return res, nil
 }
@@ -52,8 +52,10 @@ the future.
 
 See github.com/super-yaoj/yaoj-core/workflow.
 
-Problem gives workflow 3 datagroups naming "testcase", "static" and
-"submission" respectively.
+Problem gives workflow 5 datagroups naming "tests", "static", "submission",
+"subtask" and "testset" respectively. "subtask" and "testset" come from the
+Record of the subtask and the testdata group that the testcase belongs to,
+e.g. a different checker for each subtask.
 
 # Hack
 
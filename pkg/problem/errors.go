@@ -0,0 +1,11 @@
+package problem
+
+import "github.com/super-yaoj/yaoj-core/pkg/yerrors"
+
+var (
+	ErrTooManyFiles       = yerrors.New("too many files in archive")
+	ErrFileTooLarge       = yerrors.New("file in archive too large")
+	ErrArchiveTooLarge    = yerrors.New("archive uncompressed size too large")
+	ErrInvalidPath        = yerrors.New("invalid file path in archive")
+	ErrUnsupportedVersion = yerrors.New("unsupported problem version")
+)
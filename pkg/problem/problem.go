@@ -55,3 +55,19 @@ type SubtResult struct {
 func (r SubtResult) IsFull() bool {
 	return r.Fullscore-r.Score < 1e-5
 }
+
+// Hack result
+type HackResult struct {
+	// 是否 hack 成功，即被 hack 的提交没有通过该数据
+	Success bool `json:"success"`
+	// 被 hack 的提交在该数据上的评测结果
+	Result workflow.Result `json:"result"`
+}
+
+func (r HackResult) JSON() []byte {
+	data, err := json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
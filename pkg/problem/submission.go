@@ -47,24 +47,20 @@ func (r Submission) DumpTo(writer io.Writer) error {
 
 	var pathmap = map[workflow.Groupname]map[string]string{}
 
-	for group, data := range r {
-		if data == nil {
+	for group, gdata := range r {
+		if gdata == nil {
 			continue
 		}
 		if pathmap[group] == nil {
 			pathmap[group] = map[string]string{}
 		}
-		for field, store := range data {
+		for field, store := range gdata {
 			filename := string(group) + "-" + field
 			fileInzip, err := w.Create(filename)
 			if err != nil {
 				return err
 			}
-			ctnt, err := store.Get()
-			if err != nil {
-				return err
-			}
-			_, err = fileInzip.Write(ctnt)
+			_, err = data.Copy(fileInzip, store)
 			if err != nil {
 				return err
 			}
@@ -21,6 +21,9 @@ func (r *Data) newDirRecord(dir string) *DirRecord {
 // 单个测试点数据
 type TestcaseData = DirRecord
 
+// 子任务或数据组自身的数据所在的文件夹（相对于子任务或数据组的文件夹）
+const recordDir = "record"
+
 // 子任务数据
 type SubtaskData struct {
 	prob *Data
@@ -32,6 +35,8 @@ type SubtaskData struct {
 	Fullscore float64 `json:"fullscore"`
 	// 测试点数据
 	Testcases []*TestcaseData `json:"testcases"`
+	// 子任务自身的数据，评测其中的测试点时作为 workflow 的 Gsubtask 域
+	Record *DirRecord `json:"record,omitempty"`
 }
 
 func (r *SubtaskData) NewTestcase() *TestcaseData {
@@ -57,6 +62,8 @@ type TestdataGroup struct {
 	Testcases []*TestcaseData `json:"testcases"`
 	// Testcases 和 Subtasks 必有一方为 nil
 	Subtasks []*SubtaskData `json:"subtasks"`
+	// 数据组自身的数据，评测其中的测试点时作为 workflow 的 Gtestset 域
+	Record *DirRecord `json:"record,omitempty"`
 }
 
 // Whether subtask is enabled.
@@ -67,13 +74,26 @@ func (r *TestdataGroup) IsSubtask() bool {
 
 func (r *TestdataGroup) initProb(prob *Data) {
 	r.prob = prob
+	if r.Record != nil {
+		r.Record.prob = prob
+	}
 	for _, td := range r.Testcases {
-		td.prob = prob
+		if td != nil {
+			td.prob = prob
+		}
 	}
 	for _, sd := range r.Subtasks {
+		if sd == nil {
+			continue
+		}
 		sd.prob = prob
+		if sd.Record != nil {
+			sd.Record.prob = prob
+		}
 		for _, td := range sd.Testcases {
-			td.prob = prob
+			if td != nil {
+				td.prob = prob
+			}
 		}
 	}
 }
@@ -105,6 +125,7 @@ func (r *TestdataGroup) NewSubtask(fullscore float64, method CalcMethod) *Subtas
 		Dir:       tdir,
 		Fullscore: fullscore,
 		Method:    method,
+		Record:    r.prob.newDirRecord(path.Join(tdir, recordDir)),
 	}
 	r.Subtasks = append(r.Subtasks, res)
 	return res
@@ -116,5 +137,6 @@ func (r *Data) newTestdataGroup(dir string) *TestdataGroup {
 		prob:      r,
 		Dir:       dir,
 		Fullscore: r.Fullscore,
+		Record:    r.newDirRecord(path.Join(dir, recordDir)),
 	}
 }
@@ -0,0 +1,243 @@
+package problem
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+)
+
+// 诊断信息的严重程度，与 workflow 的问题共用
+type Severity = workflow.Severity
+
+const (
+	// 仅作提示
+	Info = workflow.Info
+	// 可能导致评测结果不符合预期
+	Warning = workflow.Warning
+	// 题目无法正常评测
+	Error = workflow.Error
+)
+
+// 一条诊断信息
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// 出现问题的位置，例如 "data.subtasks[0].testcases[1]"
+	Path string `json:"path"`
+	Msg  string `json:"msg"`
+}
+
+func (r Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", r.Severity, r.Path, r.Msg)
+}
+
+type Diagnostics []Diagnostic
+
+// 是否存在 Error 级别的诊断信息
+func (r Diagnostics) HasError() bool {
+	for _, d := range r {
+		if d.Severity >= Error {
+			return true
+		}
+	}
+	return false
+}
+
+// 每行一条诊断信息
+func (r Diagnostics) String() string {
+	var lines []string
+	for _, d := range r {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *Diagnostics) add(severity Severity, path string, format string, args ...any) {
+	*r = append(*r, Diagnostic{
+		Severity: severity,
+		Path:     path,
+		Msg:      fmt.Sprintf(format, args...),
+	})
+}
+
+// workflow 中某个域需要的所有字段（排序后）
+func inboundFields(wk *workflow.Workflow, group workflow.Groupname) []string {
+	var res []string
+	for field := range wk.Inbound[group] {
+		res = append(res, field)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// 所有的字段，r 为 nil 时没有字段
+func (r *DirRecord) fields() map[string]bool {
+	res := map[string]bool{}
+	if r == nil {
+		return res
+	}
+	r.Range(func(field, name string) {
+		res[field] = true
+	})
+	return res
+}
+
+// 检查记录是否包含 workflow 需要的字段
+func (r *DirRecord) validate(path string, required []string) Diagnostics {
+	var res Diagnostics
+	fields := r.fields()
+	for _, field := range required {
+		if !fields[field] {
+			res.add(Error, path, "missing field %q required by workflow", field)
+		}
+	}
+	return res
+}
+
+// 题目提供给 workflow 的域
+var providedGroups = []workflow.Groupname{
+	workflow.Gtests, workflow.Gstatic, workflow.Gsubm, workflow.Gsubtask, workflow.Gtestset,
+}
+
+// 检查题目数据是否完整、一致
+//
+// 检查的内容包括 workflow 本身（见 [workflow.Workflow.Validate]）、workflow
+// 需要的测试点、子任务、数据组与静态字段、数据组的结构、子任务的分数、hack 的
+// 配置等。
+//
+// 分析器是否存在需要由评测端检查。
+func (r *Data) Validate() Diagnostics {
+	var res Diagnostics
+
+	if r.Fullscore <= 0 {
+		res.add(Warning, "fullscore", "non-positive fullscore %v", r.Fullscore)
+	}
+	if r.AnalyzerName == "" {
+		res.add(Error, "analyzer", "analyzer not set")
+	}
+	if r.Workflow == nil {
+		res.add(Error, "workflow", "workflow not set")
+		return res
+	}
+	for _, issue := range r.Workflow.Validate() {
+		res.add(issue.Severity, "workflow."+issue.Path, "%s", issue.Msg)
+	}
+	for gname := range r.Workflow.Inbound {
+		if utils.FindIndex(providedGroups, gname) < 0 {
+			res.add(Error, "workflow.inbound."+string(gname), "group not provided by problem")
+		}
+	}
+	for name, record := range map[string]*DirRecord{
+		"static":    r.Static,
+		"statement": r.Statement,
+		"tutorial":  r.Tutorial,
+		"attached":  r.Attached,
+	} {
+		if record == nil {
+			res.add(Error, name, "record not set")
+		}
+	}
+
+	// static
+	if r.Static != nil {
+		res = append(res, r.Static.validate("static", inboundFields(r.Workflow, workflow.Gstatic))...)
+	}
+	// submission
+	for _, field := range inboundFields(r.Workflow, workflow.Gsubm) {
+		if _, ok := r.Submission[field]; !ok {
+			res.add(Warning, "submission_config", "no limitation for field %q", field)
+		}
+	}
+
+	// testdata
+	tests := inboundFields(r.Workflow, workflow.Gtests)
+	for name, group := range map[string]*TestdataGroup{
+		"pretest": r.Pretest,
+		"extra":   r.Extra,
+		"data":    r.Data,
+	} {
+		if group == nil {
+			res.add(Error, name, "testdata group not set")
+			continue
+		}
+		res = append(res, group.validate(name, r.Workflow)...)
+	}
+
+	// hack
+	if (r.HackFields == nil) != (r.HackIOMap == nil) {
+		res.add(Error, "hack_config", "hack_config and hack_map should be both set or both unset")
+	} else if r.Hackable() {
+		for field, bound := range r.HackIOMap {
+			node, ok := r.Workflow.Node[bound.Name]
+			if !ok {
+				res.add(Error, "hack_map."+field, "unknown node %q", bound.Name)
+				continue
+			}
+			if utils.FindIndex(node.OutputLabel(), bound.Label) < 0 {
+				res.add(Error, "hack_map."+field, "node %q has no output %q", bound.Name, bound.Label)
+			}
+		}
+		for _, group := range []workflow.Groupname{workflow.Gsubtask, workflow.Gtestset} {
+			if len(inboundFields(r.Workflow, group)) > 0 {
+				res.add(Error, "hack_config", "fields of group %q required by workflow are not provided when hacking", group)
+			}
+		}
+		for _, field := range tests {
+			_, submitted := r.HackFields[field]
+			_, generated := r.HackIOMap[field]
+			if !submitted && !generated {
+				res.add(Error, "hack_config", "field %q required by workflow is neither submitted nor generated", field)
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
+
+func (r *TestdataGroup) validate(name string, wk *workflow.Workflow) Diagnostics {
+	var res Diagnostics
+	if r.Testcases != nil && r.Subtasks != nil {
+		res.add(Error, name, "both testcases and subtasks are set")
+	}
+	if r.Subtasks != nil && r.Method == Msum {
+		var sum float64
+		for _, subtask := range r.Subtasks {
+			sum += subtask.Fullscore
+		}
+		if math.Abs(sum-r.Fullscore) > 1e-6 {
+			res.add(Error, name, "sum of subtask fullscores %v differs from fullscore %v", sum, r.Fullscore)
+		}
+	}
+	tests := inboundFields(wk, workflow.Gtests)
+	subtask := inboundFields(wk, workflow.Gsubtask)
+	res = append(res, r.Record.validate(name+".record", inboundFields(wk, workflow.Gtestset))...)
+	if len(r.Testcases) > 0 && len(subtask) > 0 {
+		res.add(Error, name, "subtask fields required by workflow but subtasks are not enabled")
+	}
+	check := func(path string, testcases []*TestcaseData) {
+		for i, testcase := range testcases {
+			path := fmt.Sprintf("%s.testcases[%d]", path, i)
+			if testcase == nil {
+				res.add(Error, path, "testcase not set")
+				continue
+			}
+			res = append(res, testcase.validate(path, tests)...)
+		}
+	}
+	check(name, r.Testcases)
+	for i, sd := range r.Subtasks {
+		path := fmt.Sprintf("%s.subtasks[%d]", name, i)
+		if sd == nil {
+			res.add(Error, path, "subtask not set")
+			continue
+		}
+		res = append(res, sd.Record.validate(path+".record", subtask)...)
+		check(path, sd.Testcases)
+	}
+	return res
+}
@@ -0,0 +1,51 @@
+package problem
+
+import (
+	"encoding/json"
+
+	"github.com/super-yaoj/yaoj-core/pkg/workflow"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// problem.json 格式的当前版本
+//
+// 格式发生不兼容的变化时增加版本号，并在 migrations 中加上对应的升级。其中的
+// workflow 有自己的版本，见 [workflow.Version]。
+const Version = 1
+
+// 文档格式的升级，migrations[i] 将版本 i 的文档升级为版本 i+1
+var migrations = []func(doc map[string]any) error{
+	// 0：没有版本号的文档，除版本号外与版本 1 相同
+	func(doc map[string]any) error {
+		return nil
+	},
+}
+
+// 将 problem.json 的内容升级到当前版本，其中的 workflow 也会升级
+//
+// 文档的版本比当前版本更新时返回 ErrUnsupportedVersion。
+func migrate(serial []byte) ([]byte, error) {
+	doc, err := workflow.DecodeDoc(serial)
+	if err != nil {
+		return nil, err
+	}
+	version, err := workflow.DocVersion(doc)
+	if err != nil {
+		return nil, yerrors.Annotated("version", doc["version"], ErrUnsupportedVersion)
+	}
+	if version > Version {
+		return nil, yerrors.Annotated("version", version, ErrUnsupportedVersion)
+	}
+	for ; version < Version; version++ {
+		if err := migrations[version](doc); err != nil {
+			return nil, yerrors.Annotated("version", version, err)
+		}
+	}
+	doc["version"] = Version
+	if wk, ok := doc["workflow"].(map[string]any); ok {
+		if err := workflow.Migrate(wk); err != nil {
+			return nil, yerrors.Situated("workflow", err)
+		}
+	}
+	return json.Marshal(doc)
+}
@@ -9,10 +9,13 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/log"
 )
 
-func zipDir(root string, dest string, lg *log.Entry) error {
+// compress 决定文件是否以 gzip 压缩的形式（文件名加上 ".gz"）存入压缩包，
+// 为 nil 表示都不压缩。隐藏文件（临时文件）会被忽略。
+func zipDir(root string, dest string, lg *log.Entry, compress func(zippath string) bool) error {
 	root = path.Clean(root)
 	file, err := os.Create(dest)
 	if err != nil {
@@ -27,7 +30,7 @@ func zipDir(root string, dest string, lg *log.Entry) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
 			return nil
 		}
 		file, err := os.Open(pathname)
@@ -45,6 +48,23 @@ func zipDir(root string, dest string, lg *log.Entry) error {
 		// This snippet happens to work because I don't use
 		// absolute pathnames, but ensure your real-world code
 		// transforms pathname into a zip-root relative pathname.
+		if compress != nil && compress(zippath) && data.CodecOf(zippath) == nil {
+			// 已经压缩过的数据不再使用 deflate
+			lg.Debugf("Create %#v", zippath+data.Gzip.Ext())
+			f, err := w.CreateHeader(&zip.FileHeader{
+				Name:   zippath + data.Gzip.Ext(),
+				Method: zip.Store,
+			})
+			if err != nil {
+				return err
+			}
+			gw := data.Gzip.NewWriter(f)
+			if _, err := io.Copy(gw, file); err != nil {
+				return err
+			}
+			return gw.Close()
+		}
+
 		lg.Debugf("Create %#v", zippath)
 		f, err := w.Create(zippath)
 		if err != nil {
@@ -66,13 +86,13 @@ func zipDir(root string, dest string, lg *log.Entry) error {
 }
 
 // https://gosamples.dev/unzip-file/
-func unzipSource(source, destination string) error {
-	// 1. Open the zip file
-	reader, err := zip.OpenReader(source)
+func unzipSource(source, destination string, limits ExtractLimits) error {
+	// 1. Open the zip file and check limits
+	arch, err := openArchive(source, limits)
 	if err != nil {
 		return err
 	}
-	defer reader.Close()
+	defer arch.Close()
 
 	// 2. Get the absolute destination path
 	destination, err = filepath.Abs(destination)
@@ -81,8 +101,13 @@ func unzipSource(source, destination string) error {
 	}
 
 	// 3. Iterate over zip files inside the archive and unzip each of them
-	for _, f := range reader.File {
-		err := unzipFile(f, destination)
+	//
+	// 文件名已经在 openArchive 中检查过，不会出现 Zip Slip
+	for _, f := range arch.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		err := arch.extractFile(f.Name, destination)
 		if err != nil {
 			return err
 		}
@@ -90,38 +115,3 @@ func unzipSource(source, destination string) error {
 
 	return nil
 }
-
-func unzipFile(f *zip.File, destination string) error {
-	// 4. Check if file paths are not vulnerable to Zip Slip
-	filePath := filepath.Join(destination, f.Name)
-	if !strings.HasPrefix(filePath, filepath.Clean(destination)+string(os.PathSeparator)) {
-		return fmt.Errorf("invalid file path: %s", filePath)
-	}
-
-	if f.FileInfo().IsDir() {
-		return nil
-	}
-
-	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
-		return err
-	}
-
-	// 6. Create a destination file for unzipped content
-	destinationFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-	if err != nil {
-		return err
-	}
-	defer destinationFile.Close()
-
-	// 7. Unzip the content of a file and copy it to the destination file
-	zippedFile, err := f.Open()
-	if err != nil {
-		return err
-	}
-	defer zippedFile.Close()
-
-	if _, err := io.Copy(destinationFile, zippedFile); err != nil {
-		return err
-	}
-	return nil
-}
@@ -1,14 +1,63 @@
-
 package processor
 
-// generated by scripts/genprocs
+import "github.com/super-yaoj/yaoj-core/pkg/utils"
+
+// 内置处理器的声明，使得不依赖评测端的程序（例如题目编辑工具、workflow 的
+// 静态检查）也能得到它们的元信息。评测端注册实现时会检查是否与声明一致，见
+// [Register]。
 func init() {
-	inLabel[`checker:testlib`]=[]string{`checker`,`input`,`output`,`answer`}
-	ouLabel[`checker:testlib`]=[]string{`xmlreport`,`stderr`,`judgerlog`}
-	inLabel[`compiler:auto`]=[]string{`source`,`option`}
-	ouLabel[`compiler:auto`]=[]string{`result`,`log`,`judgerlog`}
-	inLabel[`compiler:testlib`]=[]string{`source`}
-	ouLabel[`compiler:testlib`]=[]string{`result`,`log`,`judgerlog`}
-	inLabel[`runner:auto`]=[]string{`executable`,`stdin`,`conf`}
-	ouLabel[`runner:auto`]=[]string{`stdout`,`stderr`,`judgerlog`}
+	Declare(Meta{
+		Name:        "checker:testlib",
+		Description: "run a compiled testlib checker",
+		Input: []Port{
+			{"checker", utils.Cbinary},
+			{"input", utils.Cplain},
+			{"output", utils.Cplain},
+			{"answer", utils.Cplain},
+		},
+		Output: []Port{
+			{"xmlreport", utils.Cplain},
+			{"stderr", utils.Cplain},
+			{"judgerlog", utils.Cplain},
+		},
+	})
+	Declare(Meta{
+		Name:        "compiler:auto",
+		Description: "compile source code according to the compile option",
+		Input: []Port{
+			{"source", utils.Csource},
+			{"option", utils.Ccompconf},
+		},
+		Output: []Port{
+			{"result", utils.Cbinary},
+			{"log", utils.Cplain},
+			{"judgerlog", utils.Cplain},
+		},
+	})
+	Declare(Meta{
+		Name:        "compiler:testlib",
+		Description: "compile a C++ source with testlib.h",
+		Input: []Port{
+			{"source", utils.Csource},
+		},
+		Output: []Port{
+			{"result", utils.Cbinary},
+			{"log", utils.Cplain},
+			{"judgerlog", utils.Cplain},
+		},
+	})
+	Declare(Meta{
+		Name:        "runner:auto",
+		Description: "run an executable with the limits of the runner config",
+		Input: []Port{
+			{"executable", utils.Cbinary},
+			{"stdin", utils.Cplain},
+			{"conf", utils.Cplain},
+		},
+		Output: []Port{
+			{"stdout", utils.Cplain},
+			{"stderr", utils.Cplain},
+			{"judgerlog", utils.Cplain},
+		},
+	})
 }
@@ -0,0 +1,8 @@
+package processor
+
+import "github.com/super-yaoj/yaoj-core/pkg/yerrors"
+
+var (
+	ErrUnknownCode  = yerrors.New("unknown result code")
+	ErrInvalidParam = yerrors.New("invalid processor param")
+)
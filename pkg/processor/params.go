@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// Static parameters of a workflow node, e.g. extra compiler flags.
+//
+// Values are stored as strings (json marshalable and deterministic to hash),
+// and parsed by the typed getters.
+type Params map[string]string
+
+// Sorted keys of the params.
+func (r Params) Keys() []string {
+	var res []string
+	for key := range r {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Get string value, or def if not set.
+func (r Params) String(key string, def string) string {
+	if value, ok := r[key]; ok {
+		return value
+	}
+	return def
+}
+
+// Get integer value, or def if not set.
+func (r Params) Int(key string, def int) (int, error) {
+	value, ok := r[key]
+	if !ok {
+		return def, nil
+	}
+	res, err := strconv.Atoi(value)
+	if err != nil {
+		return def, yerrors.Annotated("param", key, ErrInvalidParam)
+	}
+	return res, nil
+}
+
+// Get float value, or def if not set.
+func (r Params) Float(key string, def float64) (float64, error) {
+	value, ok := r[key]
+	if !ok {
+		return def, nil
+	}
+	res, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return def, yerrors.Annotated("param", key, ErrInvalidParam)
+	}
+	return res, nil
+}
+
+// Get boolean value, or def if not set.
+func (r Params) Bool(key string, def bool) (bool, error) {
+	value, ok := r[key]
+	if !ok {
+		return def, nil
+	}
+	res, err := strconv.ParseBool(value)
+	if err != nil {
+		return def, yerrors.Annotated("param", key, ErrInvalidParam)
+	}
+	return res, nil
+}
@@ -7,10 +7,12 @@ package processor
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
 	"time"
 
 	"github.com/super-yaoj/yaoj-core/pkg/data"
 	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 type Bounds map[string]data.FileStore
@@ -27,7 +29,19 @@ type Processor interface {
 
 	// Given a fixed number of input files, generate output to  corresponding files
 	// with execution result. Inputs are considered unordered.
-	Process(inputs Inbounds, outputs Outbounds) (result *Result)
+	//
+	// params are the static parameters of the workflow node, which may be nil.
+	Process(inputs Inbounds, outputs Outbounds, params Params) (result *Result)
+}
+
+// Versioner is an optional interface implemented by processors whose
+// behavior may change between releases (e.g. compiler flags, toolchain or
+// bundled headers).
+//
+// The version is part of the cache key of workflow nodes, thus changing it
+// invalidates all cached results of the processor.
+type Versioner interface {
+	Version() string
 }
 
 type Code int
@@ -43,6 +57,35 @@ const (
 	ExitError
 )
 
+var codeNames = []string{
+	"Ok",
+	"RuntimeError",
+	"MemoryExceed",
+	"TimeExceed",
+	"OutputExceed",
+	"SystemError",
+	"DangerousSyscall",
+	"ExitError",
+}
+
+// Name of the code constant, e.g. "TimeExceed".
+func (r Code) String() string {
+	if r >= 0 && int(r) < len(codeNames) {
+		return codeNames[r]
+	}
+	return fmt.Sprintf("Code(%d)", int(r))
+}
+
+// Parse the name of a code constant (see [Code.String]).
+func ParseCode(name string) (Code, error) {
+	for i, s := range codeNames {
+		if s == name {
+			return Code(i), nil
+		}
+	}
+	return 0, yerrors.Annotated("code", name, ErrUnknownCode)
+}
+
 // Result of processor' execution
 //
 // Code is required, others are optional
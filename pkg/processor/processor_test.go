@@ -4,8 +4,120 @@ import (
 	"testing"
 
 	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 func TestAll(t *testing.T) {
-	t.Log(processor.All())
+	all := processor.All()
+	t.Log(all)
+	meta, ok := all["compiler:auto"]
+	if !ok || meta.Input[0] != (processor.Port{Label: "source", Type: utils.Csource}) {
+		t.Fatal("invalid builtin meta", meta)
+	}
+}
+
+// 用于测试注册的处理器
+type fakeProc struct {
+	input []string
+}
+
+func (r fakeProc) Label() ([]string, []string) {
+	return r.input, []string{"out"}
+}
+func (r fakeProc) Process(inputs processor.Inbounds, outputs processor.Outbounds, params processor.Params) *processor.Result {
+	return &processor.Result{Code: processor.Ok}
+}
+func (r fakeProc) Version() string {
+	return "2"
+}
+
+type describedProc struct {
+	fakeProc
+}
+
+func (r describedProc) Description() string {
+	return "described"
+}
+func (r describedProc) ContentType() ([]utils.CtntType, []utils.CtntType) {
+	return []utils.CtntType{utils.Csource}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	processor.Declare(processor.Meta{
+		Name:        "test:declared",
+		Description: "declared",
+		Input:       []processor.Port{{Label: "in", Type: utils.Cbinary}},
+		Output:      []processor.Port{{Label: "out", Type: utils.Cplain}},
+	})
+	if !processor.Exists("test:declared") || processor.Get("test:declared") != nil {
+		t.Fatal("invalid declaration")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("mismatched labels accepted")
+			}
+		}()
+		processor.Register("test:declared", fakeProc{[]string{"other"}})
+	}()
+
+	processor.Register("test:declared", fakeProc{[]string{"in"}})
+	meta, _ := processor.Lookup("test:declared")
+	if meta.Description != "declared" || meta.Input[0].Type != utils.Cbinary || meta.Version != "2" {
+		t.Fatal("invalid meta", meta)
+	}
+	if processor.Get("test:declared") == nil {
+		t.Fatal("implementation not registered")
+	}
+
+	processor.Register("test:described", describedProc{fakeProc{[]string{"a", "b"}}})
+	meta, _ = processor.Lookup("test:described")
+	if meta.Description != "described" || meta.Input[0].Type != utils.Csource || meta.Input[1].Type != utils.Cplain {
+		t.Fatal("invalid meta", meta)
+	}
+	if in := processor.InputLabel("test:described"); len(in) != 2 || in[1] != "b" {
+		t.Fatal("invalid input label", in)
+	}
+}
+
+func TestCode(t *testing.T) {
+	for code := processor.Ok; code <= processor.ExitError; code++ {
+		parsed, err := processor.ParseCode(code.String())
+		if err != nil || parsed != code {
+			t.Fatal("invalid code", code, parsed, err)
+		}
+	}
+	if _, err := processor.ParseCode("Accepted"); !yerrors.Is(err, processor.ErrUnknownCode) {
+		t.Fatal("invalid error", err)
+	}
+	if processor.Code(100).String() != "Code(100)" {
+		t.Fatal("invalid name", processor.Code(100))
+	}
+}
+
+func TestParams(t *testing.T) {
+	params := processor.Params{"n": "3", "x": "0.5", "b": "true", "s": "str", "bad": "x"}
+	if n, err := params.Int("n", 0); err != nil || n != 3 {
+		t.Fatal("invalid int", n, err)
+	}
+	if x, err := params.Float("x", 0); err != nil || x != 0.5 {
+		t.Fatal("invalid float", x, err)
+	}
+	if b, err := params.Bool("b", false); err != nil || !b {
+		t.Fatal("invalid bool", b, err)
+	}
+	if params.String("s", "") != "str" || params.String("none", "def") != "def" {
+		t.Fatal("invalid string")
+	}
+	if n, err := params.Int("none", 7); err != nil || n != 7 {
+		t.Fatal("invalid default", n, err)
+	}
+	if _, err := params.Int("bad", 0); !yerrors.Is(err, processor.ErrInvalidParam) {
+		t.Fatal("invalid error", err)
+	}
+	var empty processor.Params
+	if empty.String("s", "def") != "def" || len(empty.Keys()) != 0 {
+		t.Fatal("invalid nil params")
+	}
 }
@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+)
+
+// Describer is an optional interface implemented by processors to provide
+// human-readable description and content types of its labels.
+type Describer interface {
+	// One line description of the processor.
+	Description() string
+	// Content type of each input and output, in the same order as Label().
+	ContentType() (input []utils.CtntType, output []utils.CtntType)
+}
+
+// 处理器的一个输入或输出
+type Port struct {
+	Label string         `json:"label"`
+	Type  utils.CtntType `json:"type"`
+}
+
+// 处理器的元信息（用于题目编辑工具）
+type Meta struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Input       []Port `json:"input"`
+	Output      []Port `json:"output"`
+	// 处理器的版本，见 [Versioner]。只有声明而没有实现时为空
+	Version string `json:"version"`
+}
+
+// 输入与输出的 label
+func (r Meta) Label() (inputlabel []string, outputlabel []string) {
+	for _, port := range r.Input {
+		inputlabel = append(inputlabel, port.Label)
+	}
+	for _, port := range r.Output {
+		outputlabel = append(outputlabel, port.Label)
+	}
+	return
+}
+
+// 注册的处理器
+type entry struct {
+	meta Meta
+	// 只有声明时为 nil
+	proc Processor
+}
+
+var registry = struct {
+	sync.RWMutex
+	entries map[string]entry
+}{entries: map[string]entry{}}
+
+// 根据处理器的实现得到元信息（不含版本）
+//
+// 没有实现 [Describer] 时，所有的 label 的类型都是 utils.Cplain。
+func metaOf(name string, proc Processor) Meta {
+	res := Meta{Name: name}
+	input, output := proc.Label()
+	var intype, outype []utils.CtntType
+	if describer, ok := proc.(Describer); ok {
+		res.Description = describer.Description()
+		intype, outype = describer.ContentType()
+	}
+	port := func(label string, i int, types []utils.CtntType) Port {
+		if i < len(types) {
+			return Port{label, types[i]}
+		}
+		return Port{label, utils.Cplain}
+	}
+	for i, label := range input {
+		res.Input = append(res.Input, port(label, i, intype))
+	}
+	for i, label := range output {
+		res.Output = append(res.Output, port(label, i, outype))
+	}
+	return res
+}
+
+// 两组 label 是否相同
+func sameLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Declare the metadata of a processor without implementation, so that
+// programs not linking the judger (e.g. problem editing tools) know its labels.
+func Declare(meta Meta) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.entries[meta.Name] = entry{meta: meta}
+}
+
+// Register a processor implementation (used by the judger side), replacing the
+// existing one.
+//
+// If the processor was declared, its labels must agree with the declaration,
+// otherwise Register panics. Description and content types are taken from the
+// declaration unless the processor implements [Describer].
+func Register(name string, proc Processor) {
+	meta := metaOf(name, proc)
+	registry.Lock()
+	defer registry.Unlock()
+	if old, ok := registry.entries[name]; ok && old.proc == nil {
+		inlabel, oulabel := meta.Label()
+		oldin, oldou := old.meta.Label()
+		if !sameLabels(inlabel, oldin) || !sameLabels(oulabel, oldou) {
+			panic(fmt.Sprintf("processor %q: labels %v %v differ from declaration %v %v",
+				name, inlabel, oulabel, oldin, oldou))
+		}
+		if _, ok := proc.(Describer); !ok {
+			meta = old.meta
+		}
+	}
+	registry.entries[name] = entry{meta: meta, proc: proc}
+}
+
+// Get the implementation of a processor, nil if not registered (or only declared).
+func Get(name string) Processor {
+	item, _ := lookup(name)
+	return item.proc
+}
+
+func lookup(name string) (entry, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	item, ok := registry.entries[name]
+	return item, ok
+}
+
+// Get the metadata of a processor.
+func Lookup(name string) (Meta, bool) {
+	item, ok := lookup(name)
+	if !ok {
+		return Meta{}, false
+	}
+	meta := item.meta
+	if versioner, ok := item.proc.(Versioner); ok {
+		meta.Version = versioner.Version()
+	}
+	return meta, true
+}
+
+// Sorted names of all processors.
+func Names() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	var res []string
+	for name := range registry.entries {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Get all processor's metadata (used by cook)
+func All() map[string]Meta {
+	res := map[string]Meta{}
+	for _, name := range Names() {
+		res[name], _ = Lookup(name)
+	}
+	return res
+}
+
+// Get input label of processors.
+func InputLabel(name string) []string {
+	item, _ := lookup(name)
+	res, _ := item.meta.Label()
+	return res
+}
+
+// Get output label of processors.
+func OutputLabel(name string) []string {
+	item, _ := lookup(name)
+	_, res := item.meta.Label()
+	return res
+}
+
+// Whether the processor is registered or declared.
+func Exists(name string) bool {
+	_, ok := lookup(name)
+	return ok
+}
@@ -32,14 +32,51 @@ func (r *Builder) tryInit() {
 // key: whether its a key node. (deprecated)
 //
 // cache: whether caching its result in global cache.
-func (r *Builder) SetNode(name string, procName string, key bool, cache bool) {
+//
+// params: static parameters passed to the processor, merged in order.
+//
+// Conditions of an existing node are kept.
+func (r *Builder) SetNode(name string, procName string, key bool, cache bool, params ...processor.Params) {
 	r.tryInit()
+	var merged processor.Params
+	for _, param := range params {
+		for k, v := range param {
+			if merged == nil {
+				merged = processor.Params{}
+			}
+			merged[k] = v
+		}
+	}
 	r.Nodes[name] = Node{
-		ProcName: procName,
-		Cache:    cache,
+		ProcName:   procName,
+		Cache:      cache,
+		Params:     merged,
+		Conditions: r.Nodes[name].Conditions,
 	}
 }
 
+// Add or update a composite node, see [Composite].
+//
+// outbound: the output labels of the node and their corresponding outputs of wk.
+func (r *Builder) SetComposite(name string, wk *Workflow, outbound Outbounds) {
+	r.tryInit()
+	r.Nodes[name] = Node{
+		Composite: &Composite{
+			Workflow: wk,
+			Outbound: outbound,
+		},
+		Conditions: r.Nodes[name].Conditions,
+	}
+}
+
+// Add a condition to an existing node, see [Condition].
+func (r *Builder) AddCondition(name string, cond Condition) {
+	r.tryInit()
+	node := r.Nodes[name]
+	node.Conditions = append(node.Conditions, cond)
+	r.Nodes[name] = node
+}
+
 func (r *Builder) AddEdge(from, frlabel, to, tolabel string) {
 	r.tryInit()
 	r.Edges = append(r.Edges, []string{from, frlabel, to, tolabel})
@@ -72,8 +109,8 @@ func (r *Builder) Workflow() (*Workflow, error) {
 
 	for _, edge := range r.Edges {
 		from, frlabel, to, tolabel := edge[0], edge[1], edge[2], edge[3]
-		frlabelIndex := idxOf(processor.OutputLabel(graph.Node[from].ProcName), frlabel)
-		tolabelIndex := idxOf(processor.InputLabel(graph.Node[to].ProcName), tolabel)
+		frlabelIndex := idxOf(graph.Node[from].OutputLabel(), frlabel)
+		tolabelIndex := idxOf(graph.Node[to].InputLabel(), tolabel)
 
 		if _, ok := graph.Node[from]; !ok {
 			return nil, yerrors.Annotated("edge", edge, ErrInvalidEdge)
@@ -97,9 +134,9 @@ func (r *Builder) Workflow() (*Workflow, error) {
 	}
 	for _, edge := range r.Inbounds {
 		group, field, to, tolabel := edge[0], edge[1], edge[2], edge[3]
-		tolabelIndex := idxOf(processor.InputLabel(graph.Node[to].ProcName), tolabel)
+		tolabelIndex := idxOf(graph.Node[to].InputLabel(), tolabel)
 
-		if Groupname(group) != Gtests && Groupname(group) != Gstatic && Groupname(group) != Gsubm {
+		if !Groupname(group).Valid() {
 			return nil, yerrors.Situated("Builder.AddInbound", ErrInvalidGroupname)
 		}
 
@@ -124,12 +161,16 @@ func (r *Builder) Workflow() (*Workflow, error) {
 		grp[field] = append(grp[field], Inbound{to, tolabel})
 	}
 	for name, node := range graph.Node {
-		for _, label := range processor.InputLabel(node.ProcName) {
+		for _, label := range node.InputLabel() {
 			if !get(name, label) {
 				return nil, yerrors.Annotated(name, label, ErrIncompleteNodeInput)
 			}
 		}
 	}
+	// 其余的问题（例如环、未知的 processor）
+	if issues := graph.Validate(); issues.HasError() {
+		return nil, yerrors.Annotated("issues", issues.String(), ErrInvalidWorkflow)
+	}
 	return graph, nil
 }
 
@@ -27,9 +27,20 @@ func TestBuilder(t *testing.T) {
 			t.Fatal(err)
 		}
 	})
+	t.Run("CustomGroup", func(t *testing.T) {
+		var builder workflow.Builder
+		builder.SetNode("run", "runner:auto", true, false)
+		builder.AddInbound("user-defined", "exe", "run", "executable")
+		builder.AddInbound(workflow.Gsubtask, "stdin", "run", "stdin")
+		builder.AddInbound(workflow.Gstatic, "runconf", "run", "conf")
+		_, err := builder.Workflow()
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
 	t.Run("InvalidGroupname", func(t *testing.T) {
 		var builder workflow.Builder
-		builder.AddInbound("badgroup", "", "", "")
+		builder.AddInbound("bad group", "", "", "")
 		_, err := builder.Workflow()
 		if !yerrors.Is(err, workflow.ErrInvalidGroupname) {
 			t.Fatal(err)
@@ -107,6 +118,27 @@ func TestBuilder(t *testing.T) {
 			t.Fatal(err)
 		}
 	})
+	t.Run("InvalidWorkflow(Cycle)", func(t *testing.T) {
+		var builder workflow.Builder
+		builder.SetNode("compile", "compiler:testlib", false, false)
+		builder.SetNode("run", "runner:auto", false, false)
+		builder.AddEdge("compile", "result", "run", "executable")
+		builder.AddEdge("run", "stdout", "compile", "source")
+		builder.AddInbound(workflow.Gstatic, "conf", "run", "conf")
+		builder.AddInbound(workflow.Gtests, "input", "run", "stdin")
+		_, err := builder.Workflow()
+		if !yerrors.Is(err, workflow.ErrInvalidWorkflow) {
+			t.Fatal(err)
+		}
+	})
+	t.Run("InvalidWorkflow(UnknownProcessor)", func(t *testing.T) {
+		var builder workflow.Builder
+		builder.SetNode("run", "runner:unknown", false, false)
+		_, err := builder.Workflow()
+		if !yerrors.Is(err, workflow.ErrInvalidWorkflow) {
+			t.Fatal(err)
+		}
+	})
 	t.Run("IncompleteNodeInput", func(t *testing.T) {
 		var builder workflow.Builder
 		builder.SetNode("runner", "runner:auto", true, false)
@@ -0,0 +1,218 @@
+package workflow
+
+import (
+	"sort"
+
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// 复合结点内部结点的名字与外部结点的名字之间的分隔符，例如 "checker/compile"
+const Separator = "/"
+
+// 复合结点：以一个 workflow 作为结点
+//
+// 内部 workflow 的读入数据的字段（不区分域）即为复合结点的输入 label，因此
+// 不同域中的字段不能重名（见 [Workflow.Validate]）。Outbound 中选出的内部结点的
+// 输出即为复合结点的输出 label。评测时复合结点会被展开，见 [Workflow.Expand]。
+type Composite struct {
+	Workflow *Workflow `json:"workflow"`
+	// 复合结点的输出 label 对应的内部结点的输出
+	Outbound Outbounds `json:"outbound"`
+}
+
+// 输入 label（排序后）
+func (r *Composite) inputLabel() []string {
+	if r.Workflow == nil {
+		return nil
+	}
+	fields := map[string]bool{}
+	for _, group := range r.Workflow.Inbound {
+		for field := range group {
+			fields[field] = true
+		}
+	}
+	var res []string
+	for field := range fields {
+		res = append(res, field)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// 输出 label（排序后）
+func (r *Composite) outputLabel() []string {
+	var res []string
+	for label := range r.Outbound {
+		res = append(res, label)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// 结点的输入 label，复合结点见 [Composite]
+func (r Node) InputLabel() []string {
+	if r.Composite != nil {
+		return r.Composite.inputLabel()
+	}
+	return processor.InputLabel(r.ProcName)
+}
+
+// 结点的输出 label，复合结点见 [Composite]
+func (r Node) OutputLabel() []string {
+	if r.Composite != nil {
+		return r.Composite.outputLabel()
+	}
+	return processor.OutputLabel(r.ProcName)
+}
+
+// 是否含有复合结点
+func (r *Workflow) hasComposite() bool {
+	for _, node := range r.Node {
+		if node.Composite != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// 将复合结点的输出转化为展开后的结点的输出，其他的输出保持不变
+func (r *Workflow) Resolve(bound Outbound) (Outbound, error) {
+	node, ok := r.Node[bound.Name]
+	if !ok || node.Composite == nil {
+		return bound, nil
+	}
+	if node.Composite.Workflow == nil {
+		return bound, yerrors.Annotated("node", bound.Name, ErrInvalidComposite)
+	}
+	inner, ok := node.Composite.Outbound[bound.Label]
+	if !ok {
+		return bound, yerrors.Annotated("outbound", bound, ErrInvalidOutputLabel)
+	}
+	res, err := node.Composite.Workflow.Resolve(inner)
+	if err != nil {
+		return bound, yerrors.Annotated("node", bound.Name, err)
+	}
+	return Outbound{bound.Name + Separator + res.Name, res.Label}, nil
+}
+
+// 将所有的复合结点（递归地）展开为普通的结点
+//
+// 复合结点 name 的内部结点 x 展开后的名字为 name/x。连向复合结点的输入 label
+// 的边会连向内部 workflow 中对应字段的所有端口，从复合结点的输出 label 出发的边
+// 则从对应的内部结点的输出出发。
+//
+// 复合结点的执行条件会加到所有内部结点上；依赖于复合结点的执行条件则转化为依赖
+// 于其所有内部结点的条件（全部满足时才满足）。
+func (r *Workflow) Expand() (*Workflow, error) {
+	res := New()
+	// 复合结点展开后的内部 workflow
+	inner := map[string]*Workflow{}
+	for name, node := range r.Node {
+		if node.Composite == nil {
+			res.Node[name] = node
+			continue
+		}
+		if node.Composite.Workflow == nil {
+			return nil, yerrors.Annotated("node", name, ErrInvalidComposite)
+		}
+		wk, err := node.Composite.Workflow.Expand()
+		if err != nil {
+			return nil, yerrors.Annotated("node", name, err)
+		}
+		inner[name] = wk
+	}
+
+	// 条件中的结点展开后对应的结点
+	leaves := func(name string) []string {
+		wk, ok := inner[name]
+		if !ok {
+			return []string{name}
+		}
+		var res []string
+		for leaf := range wk.Node {
+			res = append(res, name+Separator+leaf)
+		}
+		sort.Strings(res)
+		return res
+	}
+	expandConditions := func(conds []Condition) []Condition {
+		var res []Condition
+		for _, cond := range conds {
+			for _, leaf := range leaves(cond.Node) {
+				res = append(res, Condition{Node: leaf, Code: cond.Code, Not: cond.Not})
+			}
+		}
+		return res
+	}
+
+	for name, node := range res.Node {
+		node.Conditions = expandConditions(node.Conditions)
+		res.Node[name] = node
+	}
+	for name, wk := range inner {
+		outer := expandConditions(r.Node[name].Conditions)
+		for leaf, node := range wk.Node {
+			var conds []Condition
+			for _, cond := range node.Conditions {
+				conds = append(conds, Condition{Node: name + Separator + cond.Node, Code: cond.Code, Not: cond.Not})
+			}
+			node.Conditions = append(conds, outer...)
+			res.Node[name+Separator+leaf] = node
+		}
+	}
+
+	// 输入端口展开后对应的端口
+	inputs := func(bound Inbound) []Inbound {
+		wk, ok := inner[bound.Name]
+		if !ok {
+			return []Inbound{bound}
+		}
+		var groups []string
+		for gname := range wk.Inbound {
+			groups = append(groups, string(gname))
+		}
+		sort.Strings(groups)
+		var res []Inbound
+		for _, gname := range groups {
+			for _, b := range wk.Inbound[Groupname(gname)][bound.Label] {
+				res = append(res, Inbound{bound.Name + Separator + b.Name, b.Label})
+			}
+		}
+		return res
+	}
+
+	for _, edge := range r.Edge {
+		from, err := r.Resolve(edge.From)
+		if err != nil {
+			return nil, err
+		}
+		for _, to := range inputs(edge.To) {
+			res.Edge = append(res.Edge, Edge{from, to})
+		}
+	}
+	var names []string
+	for name := range inner {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, edge := range inner[name].Edge {
+			res.Edge = append(res.Edge, Edge{
+				Outbound{name + Separator + edge.From.Name, edge.From.Label},
+				Inbound{name + Separator + edge.To.Name, edge.To.Label},
+			})
+		}
+	}
+
+	for gname, group := range r.Inbound {
+		fields := InboundFields{}
+		for field, bounds := range group {
+			for _, bound := range bounds {
+				fields[field] = append(fields[field], inputs(bound)...)
+			}
+		}
+		res.Inbound[gname] = fields
+	}
+	return res, nil
+}
@@ -0,0 +1,253 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 读入数据的一个映射：域 Group 的字段 Field 连向 To
+type InboundMapping struct {
+	Group Groupname `json:"group"`
+	Field string    `json:"field"`
+	To    Inbound   `json:"to"`
+}
+
+func (r InboundMapping) String() string {
+	return fmt.Sprintf("%s.%s -> %s.%s", r.Group, r.Field, r.To.Name, r.To.Label)
+}
+
+func (r Edge) String() string {
+	return fmt.Sprintf("%s.%s -> %s.%s", r.From.Name, r.From.Label, r.To.Name, r.To.Label)
+}
+
+// 结点的变化
+type NodeChange struct {
+	Name string `json:"name"`
+	// 变化的属性（排序后），取值为 "processor", "composite", "params", "cache",
+	// "conditions"
+	Fields []string `json:"fields"`
+}
+
+// 两个 workflow 之间的差异，所有的列表都是排序后的
+type Difference struct {
+	AddedNodes   []string     `json:"added_nodes"`
+	RemovedNodes []string     `json:"removed_nodes"`
+	ChangedNodes []NodeChange `json:"changed_nodes"`
+
+	AddedEdges   []Edge `json:"added_edges"`
+	RemovedEdges []Edge `json:"removed_edges"`
+
+	AddedInbounds   []InboundMapping `json:"added_inbounds"`
+	RemovedInbounds []InboundMapping `json:"removed_inbounds"`
+
+	// 新的 workflow 中缓存的结果失效的结点，见 [Diff]
+	Invalidated []string `json:"invalidated"`
+}
+
+// 两个 workflow 是否相同
+func (r *Difference) Empty() bool {
+	return len(r.AddedNodes) == 0 && len(r.RemovedNodes) == 0 && len(r.ChangedNodes) == 0 &&
+		len(r.AddedEdges) == 0 && len(r.RemovedEdges) == 0 &&
+		len(r.AddedInbounds) == 0 && len(r.RemovedInbounds) == 0
+}
+
+// 每行一个差异，+ 表示增加，- 表示删除，~ 表示修改，最后一行为缓存失效的结点
+func (r *Difference) String() string {
+	var lines []string
+	for _, name := range r.AddedNodes {
+		lines = append(lines, "+ node "+name)
+	}
+	for _, name := range r.RemovedNodes {
+		lines = append(lines, "- node "+name)
+	}
+	for _, change := range r.ChangedNodes {
+		lines = append(lines, fmt.Sprintf("~ node %s: %s", change.Name, strings.Join(change.Fields, ", ")))
+	}
+	for _, edge := range r.AddedEdges {
+		lines = append(lines, "+ edge "+edge.String())
+	}
+	for _, edge := range r.RemovedEdges {
+		lines = append(lines, "- edge "+edge.String())
+	}
+	for _, bound := range r.AddedInbounds {
+		lines = append(lines, "+ inbound "+bound.String())
+	}
+	for _, bound := range r.RemovedInbounds {
+		lines = append(lines, "- inbound "+bound.String())
+	}
+	if len(r.Invalidated) > 0 {
+		lines = append(lines, "invalidated: "+strings.Join(r.Invalidated, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 是否相等（json 序列化后比较）
+func jsonEqual(a, b any) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ja) == string(jb)
+}
+
+// 结点之间变化的属性
+func nodeChanges(a, b Node) (res []string) {
+	if a.ProcName != b.ProcName {
+		res = append(res, "processor")
+	}
+	if !jsonEqual(a.Composite, b.Composite) {
+		res = append(res, "composite")
+	}
+	if !jsonEqual(a.Params, b.Params) {
+		res = append(res, "params")
+	}
+	if a.Cache != b.Cache {
+		res = append(res, "cache")
+	}
+	if !jsonEqual(a.Conditions, b.Conditions) {
+		res = append(res, "conditions")
+	}
+	sort.Strings(res)
+	return
+}
+
+// 所有的读入数据映射（去重）
+func (r *Workflow) inboundMappings() map[InboundMapping]bool {
+	res := map[InboundMapping]bool{}
+	for gname, group := range r.Inbound {
+		for field, bounds := range group {
+			for _, bound := range bounds {
+				res[InboundMapping{gname, field, bound}] = true
+			}
+		}
+	}
+	return res
+}
+
+// 比较从 a 到 b 的变化
+//
+// 复合结点作为一个整体比较。结点的缓存以 processor、参数与输入的内容为键，因此
+// 以下结点在 b 中缓存的结果会失效（不再能复用 a 评测时的缓存）：新增的结点、
+// processor、参数或者复合结点内容变化的结点、输入的连接（边或读入数据）变化的
+// 结点，以及在 b 中（通过边）依赖于它们的结点。只修改了 cache 或者执行条件的
+// 结点不会使缓存失效，但评测结果仍可能变化，此时 [Difference.Empty] 为 false。
+func Diff(a, b *Workflow) *Difference {
+	res := &Difference{}
+	invalid := map[string]bool{}
+
+	for name, node := range b.Node {
+		old, ok := a.Node[name]
+		if !ok {
+			res.AddedNodes = append(res.AddedNodes, name)
+			invalid[name] = true
+			continue
+		}
+		fields := nodeChanges(old, node)
+		if len(fields) == 0 {
+			continue
+		}
+		res.ChangedNodes = append(res.ChangedNodes, NodeChange{Name: name, Fields: fields})
+		for _, field := range fields {
+			if field == "processor" || field == "composite" || field == "params" {
+				invalid[name] = true
+			}
+		}
+	}
+	for name := range a.Node {
+		if _, ok := b.Node[name]; !ok {
+			res.RemovedNodes = append(res.RemovedNodes, name)
+		}
+	}
+
+	edges := func(wk *Workflow) map[Edge]bool {
+		res := map[Edge]bool{}
+		for _, edge := range wk.Edge {
+			res[edge] = true
+		}
+		return res
+	}
+	ea, eb := edges(a), edges(b)
+	for edge := range eb {
+		if !ea[edge] {
+			res.AddedEdges = append(res.AddedEdges, edge)
+			invalid[edge.To.Name] = true
+		}
+	}
+	for edge := range ea {
+		if !eb[edge] {
+			res.RemovedEdges = append(res.RemovedEdges, edge)
+			invalid[edge.To.Name] = true
+		}
+	}
+
+	ia, ib := a.inboundMappings(), b.inboundMappings()
+	for bound := range ib {
+		if !ia[bound] {
+			res.AddedInbounds = append(res.AddedInbounds, bound)
+			invalid[bound.To.Name] = true
+		}
+	}
+	for bound := range ia {
+		if !ib[bound] {
+			res.RemovedInbounds = append(res.RemovedInbounds, bound)
+			invalid[bound.To.Name] = true
+		}
+	}
+
+	// 沿着 b 中的边传播
+	for queue := keys(invalid); len(queue) > 0; {
+		name := queue[0]
+		queue = queue[1:]
+		for _, edge := range b.EdgeFrom(name) {
+			if !invalid[edge.To.Name] {
+				invalid[edge.To.Name] = true
+				queue = append(queue, edge.To.Name)
+			}
+		}
+	}
+	for name := range invalid {
+		if _, ok := b.Node[name]; ok {
+			res.Invalidated = append(res.Invalidated, name)
+		}
+	}
+
+	sort.Strings(res.AddedNodes)
+	sort.Strings(res.RemovedNodes)
+	sort.Strings(res.Invalidated)
+	sort.Slice(res.ChangedNodes, func(i, j int) bool {
+		return res.ChangedNodes[i].Name < res.ChangedNodes[j].Name
+	})
+	sortEdges(res.AddedEdges)
+	sortEdges(res.RemovedEdges)
+	sortMappings(res.AddedInbounds)
+	sortMappings(res.RemovedInbounds)
+	return res
+}
+
+// 排序后的键
+func keys(m map[string]bool) []string {
+	var res []string
+	for key := range m {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].String() < edges[j].String()
+	})
+}
+
+func sortMappings(bounds []InboundMapping) {
+	sort.Slice(bounds, func(i, j int) bool {
+		return bounds[i].String() < bounds[j].String()
+	})
+}
@@ -19,6 +19,16 @@ to one of the inbounds of the destination (node).
 
 Datagroups is where all data files are given from.
 
+A node may declare conditions on the results of its upstream nodes, e.g.
+"only if compile is Ok". A node whose conditions are not satisfied, or one of
+whose upstream nodes is skipped, is skipped instead of being executed, and is
+marked so for the analyzer.
+
+A node may also be a composite node, i.e. a whole workflow used as a node.
+Fields of the inner workflow's datagroups become its inbounds, and selected
+outbounds of inner nodes become its outbounds. Composite nodes are expanded
+before judgement, with inner nodes named like "checker/compile".
+
 # Analyzer
 
 An analyzer examines up all nodes' execution result and all generated files to
@@ -38,6 +48,8 @@ submission.
 
 - Global (level 2): cache data in file system, which provides cross-submission
 cache sharing. This is extremely helpful when performing problem rejudge which
-eliminates tons of compiling time.
+eliminates tons of compiling time. The global cache lives in the worker's
+directory, survives restarts, and evicts least recently used entries once its
+size budget is exceeded.
 */
 package workflow
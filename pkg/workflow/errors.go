@@ -11,4 +11,9 @@ var (
 	ErrInvalidOutputLabel  = yerrors.New("invalid processor output label")
 	ErrDuplicateDest       = yerrors.New("two edges have the same destination")
 	ErrIncompleteNodeInput = yerrors.New("incomplete node input")
+	ErrInvalidWorkflow     = yerrors.New("invalid workflow")
+	ErrInvalidSyntax       = yerrors.New("invalid syntax")
+	ErrDuplicateNode       = yerrors.New("duplicate node")
+	ErrInvalidComposite    = yerrors.New("composite node without workflow")
+	ErrUnsupportedVersion  = yerrors.New("unsupported format version")
 )
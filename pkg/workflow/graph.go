@@ -0,0 +1,169 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 图中的一个结点（workflow 的结点或者读入数据的字段）
+type graphNode struct {
+	id    string
+	label []string
+	// 是否为读入数据的字段
+	inbound bool
+}
+
+// 图中的一条边
+type graphEdge struct {
+	from, to string
+	label    string
+	// 是否为执行条件
+	cond bool
+}
+
+// 将 workflow 转化为确定顺序的结点和边，供导出使用
+//
+// 读入数据的每个字段（例如 submission.source）作为一个源结点。
+func (r *Workflow) graph() (nodes []graphNode, edges []graphEdge) {
+	var names []string
+	for name := range r.Node {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ids := map[string]string{}
+	for i, name := range names {
+		ids[name] = fmt.Sprint("n", i)
+		node := r.Node[name]
+		label := []string{name, node.ProcName}
+		if node.Composite != nil {
+			label[1] = "(workflow)"
+		}
+		for _, key := range node.Params.Keys() {
+			label = append(label, key+"="+node.Params[key])
+		}
+		if node.Cache {
+			label = append(label, "(cache)")
+		}
+		nodes = append(nodes, graphNode{id: ids[name], label: label})
+	}
+	// 不存在的结点也要显示出来
+	id := func(name string) string {
+		if _, ok := ids[name]; !ok {
+			ids[name] = fmt.Sprint("n", len(ids))
+			nodes = append(nodes, graphNode{id: ids[name], label: []string{name, "(missing)"}})
+		}
+		return ids[name]
+	}
+
+	var groups []string
+	for gname := range r.Inbound {
+		groups = append(groups, string(gname))
+	}
+	sort.Strings(groups)
+	for i, gname := range groups {
+		group := r.Inbound[Groupname(gname)]
+		var fields []string
+		for field := range group {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for j, field := range fields {
+			src := fmt.Sprint("i", i, "_", j)
+			nodes = append(nodes, graphNode{id: src, label: []string{gname + "." + field}, inbound: true})
+			for _, bound := range group[field] {
+				edges = append(edges, graphEdge{from: src, to: id(bound.Name), label: bound.Label})
+			}
+		}
+	}
+	for _, edge := range r.Edge {
+		edges = append(edges, graphEdge{
+			from:  id(edge.From.Name),
+			to:    id(edge.To.Name),
+			label: edge.From.Label + " → " + edge.To.Label,
+		})
+	}
+	for _, name := range names {
+		for _, cond := range r.Node[name].Conditions {
+			label := "if " + cond.Code
+			if cond.Not {
+				label = "if not " + cond.Code
+			}
+			edges = append(edges, graphEdge{from: id(cond.Node), to: ids[name], label: label, cond: true})
+		}
+	}
+	return
+}
+
+// 将多行文本转化为 DOT 的字符串，其中 \n 表示换行
+func dotQuote(lines ...string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	var res []string
+	for _, line := range lines {
+		res = append(res, replacer.Replace(line))
+	}
+	return `"` + strings.Join(res, `\n`) + `"`
+}
+
+// 导出为 Graphviz 的 DOT 格式，输出是确定的
+//
+// 结点显示名字、processor 以及是否缓存，读入数据的字段显示为虚线椭圆，边上标注
+// 输出与输入的 label，执行条件显示为点线。
+func (r *Workflow) DOT() string {
+	nodes, edges := r.graph()
+	var b strings.Builder
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("\trankdir=LR;\n")
+	for _, node := range nodes {
+		label := dotQuote(node.label...)
+		if node.inbound {
+			fmt.Fprintf(&b, "\t%s [shape=ellipse, style=dashed, label=%s];\n", node.id, label)
+		} else {
+			fmt.Fprintf(&b, "\t%s [shape=box, label=%s];\n", node.id, label)
+		}
+	}
+	for _, edge := range edges {
+		if edge.cond {
+			fmt.Fprintf(&b, "\t%s -> %s [style=dotted, label=%s];\n", edge.from, edge.to, dotQuote(edge.label))
+		} else {
+			fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", edge.from, edge.to, dotQuote(edge.label))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// 将多行文本转化为 Mermaid 的字符串，用 <br/> 换行
+func mermaidQuote(lines ...string) string {
+	replacer := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	var res []string
+	for _, line := range lines {
+		res = append(res, replacer.Replace(line))
+	}
+	return `"` + strings.Join(res, "<br/>") + `"`
+}
+
+// 导出为 Mermaid 的 flowchart，输出是确定的
+//
+// 显示的内容与 [Workflow.DOT] 相同，读入数据的字段显示为圆角结点。
+func (r *Workflow) Mermaid() string {
+	nodes, edges := r.graph()
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, node := range nodes {
+		label := mermaidQuote(node.label...)
+		if node.inbound {
+			fmt.Fprintf(&b, "\t%s(%s)\n", node.id, label)
+		} else {
+			fmt.Fprintf(&b, "\t%s[%s]\n", node.id, label)
+		}
+	}
+	for _, edge := range edges {
+		if edge.cond {
+			fmt.Fprintf(&b, "\t%s -.->|%s| %s\n", edge.from, mermaidQuote(edge.label), edge.to)
+		} else {
+			fmt.Fprintf(&b, "\t%s -->|%s| %s\n", edge.from, mermaidQuote(edge.label), edge.to)
+		}
+	}
+	return b.String()
+}
@@ -8,6 +8,7 @@ func init() {
 	var builder workflow.Builder
 	builder.SetNode("compile", "compiler:auto", false, true)
 	builder.SetNode("run", "runner:auto", true, false)
+	builder.AddCondition("run", workflow.Condition{Node: "compile", Code: "Ok"})
 
 	builder.AddInbound(workflow.Gsubm, "source", "compile", "source")
 	builder.AddInbound(workflow.Gsubm, "option", "compile", "option")
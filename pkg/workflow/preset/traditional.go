@@ -6,7 +6,7 @@ import "github.com/super-yaoj/yaoj-core/pkg/workflow"
 //
 //	Gstatic:
 //	  checker       校验器源码（testlib）
-//	  runner_config 时空限制，文件 IO 等设置
+//	  runner_config 时空限制，文件 IO 等设置（评测时可被数据组、子任务、测试点的同名字段覆盖）
 //	Gsubm:
 //	  option 源代码的语言等属性（用于哈希）
 //	  source 源代码
@@ -22,6 +22,11 @@ func init() {
 	builder.SetNode("check", "checker:testlib", false, false)
 	builder.SetNode("checker_compile", "compiler:testlib", false, true)
 
+	// 编译失败时不运行，运行失败时不校验
+	builder.AddCondition("run", workflow.Condition{Node: "compile", Code: "Ok"})
+	builder.AddCondition("check", workflow.Condition{Node: "run", Code: "Ok"})
+	builder.AddCondition("check", workflow.Condition{Node: "checker_compile", Code: "Ok"})
+
 	builder.AddEdge("checker_compile", "result", "check", "checker")
 	builder.AddEdge("run", "stdout", "check", "output")
 	builder.AddInbound(workflow.Gtests, "input", "check", "input")
@@ -0,0 +1,446 @@
+package workflow
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// 解析文本格式时的错误
+type ParseError struct {
+	// 出错的行号（从 1 开始）
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// 文本格式的一个词
+type token struct {
+	// 标识符或者字符串为 ""，否则为符号本身
+	kind  string
+	value string
+}
+
+// 不需要加引号的标识符
+var identRegexp = regexp.MustCompile(`^[A-Za-z0-9_:/-]+$`)
+
+func isIdentByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == ':' || c == '/' || c == '-'
+}
+
+// 将一行切分为词，# 之后的内容为注释
+func tokenize(line string) ([]token, error) {
+	var res []token
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			return res, nil
+		case strings.HasPrefix(line[i:], "<-") || strings.HasPrefix(line[i:], "->") ||
+			strings.HasPrefix(line[i:], "==") || strings.HasPrefix(line[i:], "!="):
+			res = append(res, token{kind: line[i : i+2]})
+			i += 2
+		case strings.ContainsRune("=(),.{}[]", rune(c)):
+			res = append(res, token{kind: string(c)})
+			i++
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(line[i:])
+			if err != nil {
+				return nil, yerrors.Annotated("string", line[i:], ErrInvalidSyntax)
+			}
+			value, _ := strconv.Unquote(quoted)
+			res = append(res, token{value: value})
+			i += len(quoted)
+		case isIdentByte(c):
+			j := i
+			for j < len(line) && isIdentByte(line[j]) && !strings.HasPrefix(line[j:], "->") {
+				j++
+			}
+			res = append(res, token{value: line[i:j]})
+			i = j
+		default:
+			return nil, yerrors.Annotated("char", string(c), ErrInvalidSyntax)
+		}
+	}
+	return res, nil
+}
+
+// 按顺序读取一行中的词
+type tokenReader struct {
+	tokens []token
+	err    error
+}
+
+func (r *tokenReader) next() (token, bool) {
+	if len(r.tokens) == 0 {
+		return token{}, false
+	}
+	tok := r.tokens[0]
+	r.tokens = r.tokens[1:]
+	return tok, true
+}
+
+// 读取一个标识符
+func (r *tokenReader) ident(what string) string {
+	if r.err != nil {
+		return ""
+	}
+	tok, ok := r.next()
+	if !ok || tok.kind != "" {
+		r.err = yerrors.Annotated("expect", what, ErrInvalidSyntax)
+	}
+	return tok.value
+}
+
+// 读取一个符号
+func (r *tokenReader) expect(kind string) {
+	if r.err != nil {
+		return
+	}
+	tok, ok := r.next()
+	if !ok || tok.kind != kind {
+		r.err = yerrors.Annotated("expect", kind, ErrInvalidSyntax)
+	}
+}
+
+// 下一个词是否为符号 kind
+func (r *tokenReader) peek(kind string) bool {
+	return r.err == nil && len(r.tokens) > 0 && r.tokens[0].kind == kind
+}
+
+// 是否已经读完所有的词
+func (r *tokenReader) end() error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.tokens) > 0 {
+		return yerrors.Annotated("unexpected", r.tokens[0].kind+r.tokens[0].value, ErrInvalidSyntax)
+	}
+	return nil
+}
+
+// 下一个词是否为关键字 value
+func (r *tokenReader) peekKeyword(value string) bool {
+	return r.peek("") && r.tokens[0].value == value
+}
+
+// 从文本格式解析 workflow
+//
+// 每行是一个结点的定义或者一条边，# 之后的内容为注释：
+//
+//	# 结点：名字 = processor[参数 = 值, ...](输入 label <- 域.字段, ...)，之后的 cache
+//	# 表示缓存结果，if 之后是执行条件（见 [Condition]），参数可以省略
+//	compile = compiler:auto[flags = "-O2 -DONLINE_JUDGE"](source <- submission.source, option <- submission.option) cache
+//	run = runner:auto(stdin <- tests.input, conf <- static.runconf) if compile == Ok
+//	check = checker:testlib(answer <- tests.output, input <- tests.input) if compile == Ok, run != TimeExceed
+//	# 边：结点.输出 label -> 结点.输入 label
+//	compile.result -> run.executable
+//
+// 复合结点（见 [Composite]）的内部 workflow 写在花括号中，其中 out 定义复合结点
+// 的输出，右花括号之后是复合结点的读入数据、cache 以及执行条件：
+//
+//	checker = {
+//		compile = compiler:testlib(source <- static.checker) cache
+//		check = checker:testlib(input <- tests.input, output <- tests.output, answer <- tests.answer)
+//		compile.result -> check.checker
+//		out report = check.xmlreport
+//	}(checker <- static.checker, input <- tests.input, answer <- tests.output)
+//	run.stdout -> checker.output
+//
+// 含有其他字符的名字可以用双引号括起来（Go 的字符串语法）。解析时不会检查
+// workflow 本身，需要时调用 [Workflow.Validate]。
+func ParseText(text []byte) (*Workflow, error) {
+	parser := &textParser{lines: strings.Split(string(text), "\n")}
+	res, _, _, err := parser.parse(false)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// 文本格式的解析器
+type textParser struct {
+	lines []string
+	// 当前的行（从 0 开始）
+	pos int
+}
+
+func (p *textParser) error(err error) error {
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	return &ParseError{Line: p.pos + 1, Err: err}
+}
+
+// 解析一个 workflow，直到文本结束
+//
+// block 为 true 时解析复合结点的内部 workflow，直到 } 所在的行，返回其中 out
+// 定义的输出以及 } 之后的词
+func (p *textParser) parse(block bool) (*Workflow, Outbounds, []token, error) {
+	res := New()
+	outbound := Outbounds{}
+	for ; p.pos < len(p.lines); p.pos++ {
+		tokens, err := tokenize(p.lines[p.pos])
+		if err != nil {
+			return nil, nil, nil, p.error(err)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		if tokens[0].kind == "}" {
+			if !block {
+				return nil, nil, nil, p.error(yerrors.Annotated("unexpected", "}", ErrInvalidSyntax))
+			}
+			return res, outbound, tokens[1:], nil
+		}
+		// out label = 结点.输出 label
+		if block && len(tokens) > 1 && tokens[0].kind == "" && tokens[0].value == "out" && tokens[1].kind == "" {
+			reader := &tokenReader{tokens: tokens[1:]}
+			label := reader.ident("output label")
+			reader.expect("=")
+			var bound Outbound
+			bound.Name = reader.ident("node name")
+			reader.expect(".")
+			bound.Label = reader.ident("output label")
+			if err := reader.end(); err != nil {
+				return nil, nil, nil, p.error(err)
+			}
+			outbound[label] = bound
+			continue
+		}
+		if err := p.parseLine(res, tokens); err != nil {
+			return nil, nil, nil, p.error(err)
+		}
+	}
+	if block {
+		p.pos--
+		return nil, nil, nil, p.error(yerrors.Annotated("expect", "}", ErrInvalidSyntax))
+	}
+	return res, nil, nil, nil
+}
+
+func (p *textParser) parseLine(wk *Workflow, tokens []token) error {
+	reader := &tokenReader{tokens: tokens}
+	name := reader.ident("node name")
+	if !reader.peek("=") {
+		var edge Edge
+		edge.From.Name = name
+		reader.expect(".")
+		edge.From.Label = reader.ident("output label")
+		reader.expect("->")
+		edge.To.Name = reader.ident("node name")
+		reader.expect(".")
+		edge.To.Label = reader.ident("input label")
+		if err := reader.end(); err != nil {
+			return err
+		}
+		wk.Edge = append(wk.Edge, edge)
+		return nil
+	}
+
+	reader.expect("=")
+	var node Node
+	if reader.peek("{") {
+		// 复合结点
+		reader.expect("{")
+		if err := reader.end(); err != nil {
+			return err
+		}
+		p.pos++
+		inner, outbound, rest, err := p.parse(true)
+		if err != nil {
+			return err
+		}
+		node.Composite = &Composite{Workflow: inner, Outbound: outbound}
+		reader = &tokenReader{tokens: rest}
+	} else {
+		node.ProcName = reader.ident("processor name")
+		if reader.peek("[") {
+			reader.expect("[")
+			node.Params = processor.Params{}
+			for reader.err == nil && !reader.peek("]") {
+				if len(node.Params) > 0 {
+					reader.expect(",")
+				}
+				key := reader.ident("param name")
+				reader.expect("=")
+				node.Params[key] = reader.ident("param value")
+			}
+			reader.expect("]")
+			if len(node.Params) == 0 {
+				node.Params = nil
+			}
+		}
+	}
+
+	type inbound struct {
+		label, group, field string
+	}
+	var inbounds []inbound
+	if reader.peek("(") {
+		reader.expect("(")
+		for reader.err == nil && !reader.peek(")") {
+			if len(inbounds) > 0 {
+				reader.expect(",")
+			}
+			var bound inbound
+			bound.label = reader.ident("input label")
+			reader.expect("<-")
+			bound.group = reader.ident("groupname")
+			reader.expect(".")
+			bound.field = reader.ident("field")
+			inbounds = append(inbounds, bound)
+		}
+		reader.expect(")")
+	}
+	if reader.peekKeyword("cache") {
+		reader.next()
+		node.Cache = true
+	}
+	if reader.peekKeyword("if") {
+		reader.next()
+		for reader.err == nil {
+			cond := Condition{Node: reader.ident("node name")}
+			if reader.peek("!=") {
+				reader.expect("!=")
+				cond.Not = true
+			} else {
+				reader.expect("==")
+			}
+			cond.Code = reader.ident("code")
+			node.Conditions = append(node.Conditions, cond)
+			if !reader.peek(",") {
+				break
+			}
+			reader.expect(",")
+		}
+	}
+	if err := reader.end(); err != nil {
+		return err
+	}
+	if _, ok := wk.Node[name]; ok {
+		return yerrors.Annotated("node", name, ErrDuplicateNode)
+	}
+	wk.Node[name] = node
+	for _, bound := range inbounds {
+		group := Groupname(bound.group)
+		if wk.Inbound[group] == nil {
+			wk.Inbound[group] = InboundFields{}
+		}
+		wk.Inbound[group][bound.field] = append(wk.Inbound[group][bound.field], Inbound{name, bound.label})
+	}
+	return nil
+}
+
+// 必要时加上引号
+func quoteIdent(s string) string {
+	if identRegexp.MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// 转化为文本格式，见 [ParseText]
+//
+// 结点按名字排序，结点的读入数据按 label 排序，边保持原有的顺序。
+func (r *Workflow) Text() string {
+	var b strings.Builder
+	r.writeText(&b, "")
+	return b.String()
+}
+
+// 每行加上缩进 indent
+func (r *Workflow) writeText(b *strings.Builder, indent string) {
+	type inbound struct {
+		label, group, field string
+	}
+	inbounds := map[string][]inbound{}
+	for gname, group := range r.Inbound {
+		for field, bounds := range group {
+			for _, bound := range bounds {
+				inbounds[bound.Name] = append(inbounds[bound.Name], inbound{bound.Label, string(gname), field})
+			}
+		}
+	}
+	var names []string
+	for name := range r.Node {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		node := r.Node[name]
+		if node.Composite != nil && node.Composite.Workflow != nil {
+			fmt.Fprintf(b, "%s%s = {\n", indent, quoteIdent(name))
+			node.Composite.Workflow.writeText(b, indent+"\t")
+			for _, label := range node.OutputLabel() {
+				bound := node.Composite.Outbound[label]
+				fmt.Fprintf(b, "%s\tout %s = %s.%s\n", indent,
+					quoteIdent(label), quoteIdent(bound.Name), quoteIdent(bound.Label))
+			}
+			fmt.Fprintf(b, "%s}", indent)
+		} else {
+			fmt.Fprintf(b, "%s%s = %s", indent, quoteIdent(name), quoteIdent(node.ProcName))
+			if len(node.Params) > 0 {
+				var params []string
+				for _, key := range node.Params.Keys() {
+					params = append(params, quoteIdent(key)+" = "+quoteIdent(node.Params[key]))
+				}
+				fmt.Fprintf(b, "[%s]", strings.Join(params, ", "))
+			}
+		}
+		bounds := inbounds[name]
+		sort.Slice(bounds, func(i, j int) bool {
+			if bounds[i].label != bounds[j].label {
+				return bounds[i].label < bounds[j].label
+			}
+			if bounds[i].group != bounds[j].group {
+				return bounds[i].group < bounds[j].group
+			}
+			return bounds[i].field < bounds[j].field
+		})
+		if len(bounds) > 0 {
+			var args []string
+			for _, bound := range bounds {
+				args = append(args, fmt.Sprintf("%s <- %s.%s",
+					quoteIdent(bound.label), quoteIdent(bound.group), quoteIdent(bound.field)))
+			}
+			fmt.Fprintf(b, "(%s)", strings.Join(args, ", "))
+		}
+		if node.Cache {
+			b.WriteString(" cache")
+		}
+		if len(node.Conditions) > 0 {
+			var conds []string
+			for _, cond := range node.Conditions {
+				op := "=="
+				if cond.Not {
+					op = "!="
+				}
+				conds = append(conds, fmt.Sprintf("%s %s %s", quoteIdent(cond.Node), op, quoteIdent(cond.Code)))
+			}
+			fmt.Fprintf(b, " if %s", strings.Join(conds, ", "))
+		}
+		b.WriteString("\n")
+	}
+	for _, edge := range r.Edge {
+		fmt.Fprintf(b, "%s%s.%s -> %s.%s\n", indent,
+			quoteIdent(edge.From.Name), quoteIdent(edge.From.Label),
+			quoteIdent(edge.To.Name), quoteIdent(edge.To.Label))
+	}
+}
@@ -0,0 +1,284 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
+	"github.com/super-yaoj/yaoj-core/pkg/utils"
+)
+
+// 问题的严重程度
+type Severity int
+
+const (
+	// 仅作提示
+	Info Severity = iota
+	// 可能导致评测结果不符合预期
+	Warning
+	// 无法正常评测
+	Error
+)
+
+func (r Severity) String() string {
+	switch r {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(r))
+}
+
+func (r Severity) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// workflow 中的一个问题
+type Issue struct {
+	Severity Severity `json:"severity"`
+	// 出现问题的位置，例如 "node.run", "edge[1]", "inbound.tests.input"
+	Path string `json:"path"`
+	Msg  string `json:"msg"`
+}
+
+func (r Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", r.Severity, r.Path, r.Msg)
+}
+
+type Issues []Issue
+
+// 是否存在 Error 级别的问题
+func (r Issues) HasError() bool {
+	for _, issue := range r {
+		if issue.Severity >= Error {
+			return true
+		}
+	}
+	return false
+}
+
+// 每行一个问题
+func (r Issues) String() string {
+	var lines []string
+	for _, issue := range r {
+		lines = append(lines, issue.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *Issues) add(severity Severity, path string, format string, args ...any) {
+	*r = append(*r, Issue{
+		Severity: severity,
+		Path:     path,
+		Msg:      fmt.Sprintf(format, args...),
+	})
+}
+
+// 静态检查 workflow，一次性返回所有的问题（按 Path 排序）
+//
+// 检查的内容包括未知的 processor、非法的边与 label、重复或缺失的输入、非法的
+// 执行条件、环（包括执行条件带来的依赖），以及没有被使用的输出（Info，它们可能
+// 由 analyzer 使用）。
+//
+// 含有复合结点时，先检查复合结点本身（包括不同域中重名的字段）以及与之相连的边，
+// 再检查展开后的 workflow（其中内部结点的名字见 [Workflow.Expand]）。
+func (r *Workflow) Validate() Issues {
+	if !r.hasComposite() {
+		return r.validate()
+	}
+	var res Issues
+	check := func(path string, bound Inbound) {
+		node, ok := r.Node[bound.Name]
+		if ok && node.Composite != nil && idxOf(node.InputLabel(), bound.Label) < 0 {
+			res.add(Error, path, "node %q has no input %q", bound.Name, bound.Label)
+		}
+	}
+	for i, edge := range r.Edge {
+		check(fmt.Sprintf("edge[%d]", i), edge.To)
+	}
+	for gname, group := range r.Inbound {
+		for field, bounds := range group {
+			for _, bound := range bounds {
+				check("inbound."+string(gname)+"."+field, bound)
+			}
+		}
+	}
+	for name, node := range r.Node {
+		if node.Composite == nil {
+			continue
+		}
+		if node.Composite.Workflow == nil {
+			res.add(Error, "node."+name, "composite node without workflow")
+			continue
+		}
+		// 输入 label 不区分域，因此不同域中的字段不能重名
+		groups := map[string]Groupname{}
+		for gname, group := range node.Composite.Workflow.Inbound {
+			for field := range group {
+				if other, ok := groups[field]; ok {
+					if other > gname {
+						other, gname = gname, other
+					}
+					res.add(Error, "node."+name, "input %q: field of both group %q and %q", field, other, gname)
+				}
+				groups[field] = gname
+			}
+		}
+		for _, label := range node.OutputLabel() {
+			bound := node.Composite.Outbound[label]
+			if inner, ok := node.Composite.Workflow.Node[bound.Name]; !ok {
+				res.add(Error, "node."+name, "output %q: unknown node %q", label, bound.Name)
+			} else if idxOf(inner.OutputLabel(), bound.Label) < 0 {
+				res.add(Error, "node."+name, "output %q: node %q has no output %q", label, bound.Name, bound.Label)
+			} else if _, err := node.Composite.Workflow.Resolve(bound); err != nil {
+				res.add(Error, "node."+name, "output %q: %v", label, err)
+			}
+		}
+	}
+	if !res.HasError() {
+		expanded, err := r.Expand()
+		if err != nil {
+			res.add(Error, "node", "%v", err)
+		} else {
+			res = append(res, expanded.validate()...)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
+
+func (r *Workflow) validate() Issues {
+	var res Issues
+
+	var names []string
+	for name := range r.Node {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !processor.Exists(r.Node[name].ProcName) {
+			res.add(Error, "node."+name, "unknown processor %q", r.Node[name].ProcName)
+		}
+	}
+
+	// 每个输入端口的来源
+	sources := map[Inbound][]string{}
+	// 被使用的输出端口
+	used := map[Outbound]bool{}
+	// 检查输入端口，返回是否合法
+	checkInbound := func(path string, bound Inbound) bool {
+		node, ok := r.Node[bound.Name]
+		if !ok {
+			res.add(Error, path, "unknown node %q", bound.Name)
+			return false
+		}
+		if processor.Exists(node.ProcName) && idxOf(processor.InputLabel(node.ProcName), bound.Label) < 0 {
+			res.add(Error, path, "node %q has no input %q", bound.Name, bound.Label)
+			return false
+		}
+		sources[bound] = append(sources[bound], path)
+		return true
+	}
+
+	for i, edge := range r.Edge {
+		path := fmt.Sprintf("edge[%d]", i)
+		if node, ok := r.Node[edge.From.Name]; !ok {
+			res.add(Error, path, "unknown node %q", edge.From.Name)
+		} else if processor.Exists(node.ProcName) && idxOf(processor.OutputLabel(node.ProcName), edge.From.Label) < 0 {
+			res.add(Error, path, "node %q has no output %q", edge.From.Name, edge.From.Label)
+		} else {
+			used[edge.From] = true
+		}
+		checkInbound(path, edge.To)
+	}
+
+	var groups []string
+	for gname := range r.Inbound {
+		groups = append(groups, string(gname))
+	}
+	sort.Strings(groups)
+	for _, gname := range groups {
+		group := Groupname(gname)
+		if !group.Valid() {
+			res.add(Error, "inbound."+gname, "invalid groupname")
+			continue
+		}
+		var fields []string
+		for field := range r.Inbound[group] {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			for _, bound := range r.Inbound[group][field] {
+				checkInbound("inbound."+gname+"."+field, bound)
+			}
+		}
+	}
+
+	for _, name := range names {
+		procName := r.Node[name].ProcName
+		for _, label := range processor.InputLabel(procName) {
+			srcs := sources[Inbound{name, label}]
+			if len(srcs) == 0 {
+				res.add(Error, "node."+name, "input %q not connected", label)
+			} else if len(srcs) > 1 {
+				res.add(Error, "node."+name, "input %q has multiple sources: %s", label, strings.Join(srcs, ", "))
+			}
+		}
+		for _, label := range processor.OutputLabel(procName) {
+			if !used[Outbound{name, label}] {
+				res.add(Info, "node."+name, "output %q not used by other nodes", label)
+			}
+		}
+	}
+
+	for _, name := range names {
+		for i, cond := range r.Node[name].Conditions {
+			path := fmt.Sprintf("node.%s.conditions[%d]", name, i)
+			if _, ok := r.Node[cond.Node]; !ok {
+				res.add(Error, path, "unknown node %q", cond.Node)
+			} else if cond.Node == name {
+				res.add(Error, "node."+name, "node depends on itself")
+			}
+			if _, err := processor.ParseCode(cond.Code); err != nil {
+				res.add(Error, path, "unknown code %q", cond.Code)
+			}
+		}
+	}
+
+	// 环
+	for _, edge := range r.Edge {
+		if edge.From.Name == edge.To.Name {
+			if _, ok := r.Node[edge.From.Name]; ok {
+				res.add(Error, "node."+edge.From.Name, "node depends on itself")
+			}
+		}
+	}
+	sorted, err := utils.TopSort(names, func(u, v string) bool {
+		return r.DependsOn(v, u)
+	})
+	if err != nil {
+		done := map[string]bool{}
+		for _, name := range sorted {
+			done[name] = true
+		}
+		var cyclic []string
+		for _, name := range names {
+			if !done[name] {
+				cyclic = append(cyclic, name)
+			}
+		}
+		res.add(Error, "edge", "cycle among nodes (or depending on it): %s", strings.Join(cyclic, ", "))
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
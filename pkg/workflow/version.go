@@ -0,0 +1,100 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
+)
+
+// workflow 序列化格式的当前版本
+//
+// 格式发生不兼容的变化时增加版本号，并在 migrations 中加上对应的升级。
+const Version = 1
+
+// 文档格式的升级，migrations[i] 将版本 i 的文档升级为版本 i+1
+//
+// 文档是 json 解析得到的 map，数字为 json.Number。
+var migrations = []func(doc map[string]any) error{
+	// 0：没有版本号的文档。结点可能带有已弃用的 key 字段
+	func(doc map[string]any) error {
+		nodes, _ := doc["node"].(map[string]any)
+		for _, node := range nodes {
+			if node, ok := node.(map[string]any); ok {
+				delete(node, "key")
+			}
+		}
+		return nil
+	},
+}
+
+// 文档的版本，没有版本号时为 0
+func DocVersion(doc map[string]any) (int, error) {
+	value, ok := doc["version"]
+	if !ok || value == nil {
+		return 0, nil
+	}
+	var version int
+	switch value := value.(type) {
+	case json.Number:
+		v, err := value.Int64()
+		if err != nil {
+			return 0, yerrors.Annotated("version", value, ErrUnsupportedVersion)
+		}
+		version = int(v)
+	case float64:
+		version = int(value)
+		if float64(version) != value {
+			return 0, yerrors.Annotated("version", value, ErrUnsupportedVersion)
+		}
+	default:
+		return 0, yerrors.Annotated("version", value, ErrUnsupportedVersion)
+	}
+	if version < 0 {
+		return 0, yerrors.Annotated("version", version, ErrUnsupportedVersion)
+	}
+	return version, nil
+}
+
+// 将（json 解析得到的）workflow 文档原地升级到当前版本，包括其中的复合结点
+//
+// 文档的版本比当前版本更新时返回 ErrUnsupportedVersion。
+func Migrate(doc map[string]any) error {
+	version, err := DocVersion(doc)
+	if err != nil {
+		return err
+	}
+	if version > Version {
+		return yerrors.Annotated("version", version, ErrUnsupportedVersion)
+	}
+	for ; version < Version; version++ {
+		if err := migrations[version](doc); err != nil {
+			return yerrors.Annotated("version", version, err)
+		}
+	}
+	doc["version"] = Version
+
+	nodes, _ := doc["node"].(map[string]any)
+	for name, node := range nodes {
+		node, _ := node.(map[string]any)
+		composite, _ := node["composite"].(map[string]any)
+		if inner, ok := composite["workflow"].(map[string]any); ok {
+			if err := Migrate(inner); err != nil {
+				return yerrors.Annotated("node", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 解析 json 文档，数字保留为 json.Number，得到的文档可以交给 [DocVersion] 与
+// [Migrate]。内嵌 workflow 的文档（例如 problem.json）也应当用它解析。
+func DecodeDoc(serial []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(serial))
+	decoder.UseNumber()
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
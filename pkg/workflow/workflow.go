@@ -6,11 +6,15 @@ import (
 	"time"
 
 	"github.com/super-yaoj/yaoj-core/pkg/data"
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
 	"github.com/super-yaoj/yaoj-core/pkg/utils"
 	"github.com/super-yaoj/yaoj-core/pkg/yerrors"
 )
 
 // workflow 数据的来源（域）
+//
+// 除了下面的域以外也可以使用自定义的域，由评测端负责提供其数据。域的名字由字母、
+// 数字、下划线和连字符组成，见 [Groupname.Valid]。
 type Groupname string
 
 const (
@@ -20,8 +24,25 @@ const (
 	Gstatic Groupname = "static"
 	// 参数者提交的数据
 	Gsubm Groupname = "submission"
+	// 测试点所在的子任务的数据，例如每个子任务不同的 checker
+	Gsubtask Groupname = "subtask"
+	// 测试点所在的数据组的数据
+	Gtestset Groupname = "testset"
 )
 
+// 域的名字是否合法
+func (r Groupname) Valid() bool {
+	if r == "" {
+		return false
+	}
+	for _, c := range []byte(r) {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
 // Bound 可以理解为 Workflow 中结点的端口
 type Bound struct {
 	// name of the node
@@ -47,10 +68,28 @@ type Edge struct {
 
 // Node of workflow
 type Node struct {
-	// processor name
+	// processor name, empty for composite node
 	ProcName string `json:"processor"`
+	// 复合结点，普通结点为 nil
+	Composite *Composite `json:"composite,omitempty"`
 	// whether caching its result in global cache
 	Cache bool `json:"cache"`
+	// 传给 processor 的静态参数，会计入结点的哈希
+	Params processor.Params `json:"params,omitempty"`
+	// 执行的条件，全部满足时才会执行，否则跳过
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// 结点执行的条件
+//
+// 上游结点 Node 的执行结果为 Code 时满足条件，Not 为 true 时取反。Node 被跳过
+// 或者没有执行时，条件总是不满足。例如 {"compile", "Ok", false} 表示编译成功，
+// {"run", "TimeExceed", true} 表示运行没有超时。
+type Condition struct {
+	Node string `json:"node"`
+	// processor.Code 的名字，见 [processor.Code.String]
+	Code string `json:"code"`
+	Not  bool   `json:"not,omitempty"`
 }
 
 // store the file path of workflow's inbound data
@@ -63,6 +102,9 @@ type InboundFields = map[string][]Inbound
 //
 // json marshalable
 type Workflow struct {
+	// 序列化格式的版本，见 [Version] 与 [Load]
+	Version int `json:"version"`
+
 	// All nodes of the workflow. Each node has its unique name, represented by
 	// the key.
 	Node map[string]Node `json:"node"`
@@ -97,11 +139,28 @@ func (r *Workflow) EdgeTo(name string) []Edge {
 	return res
 }
 
+// 结点 name 是否直接依赖于结点 from，即存在从 from 到 name 的边，或者 name
+// 的执行条件与 from 有关
+func (r *Workflow) DependsOn(name, from string) bool {
+	for _, edge := range r.Edge {
+		if edge.From.Name == from && edge.To.Name == name {
+			return true
+		}
+	}
+	for _, cond := range r.Node[name].Conditions {
+		if cond.Node == from {
+			return true
+		}
+	}
+	return false
+}
+
 // Transform workflow to its corresponding builder (for yaoj-cook)
 func (r *Workflow) Builder() *Builder {
 	var builder Builder
+	builder.tryInit()
 	for name, node := range r.Node {
-		builder.SetNode(name, node.ProcName, false, node.Cache)
+		builder.Nodes[name] = node
 	}
 	for _, edge := range r.Edge {
 		builder.AddEdge(edge.From.Name, edge.From.Label, edge.To.Name, edge.To.Label)
@@ -119,6 +178,7 @@ func (r *Workflow) Builder() *Builder {
 // Create an empty Workflow
 func New() *Workflow {
 	return &Workflow{
+		Version: Version,
 		Node:    map[string]Node{},
 		Edge:    []Edge{},
 		Inbound: map[Groupname]map[string][]Inbound{},
@@ -126,9 +186,23 @@ func New() *Workflow {
 }
 
 // Load graph from serialized data (json)
+//
+// 旧版本的文档会先升级到当前版本（见 [Migrate]），更新的版本则返回
+// ErrUnsupportedVersion。加载时不会检查 workflow 本身，需要时调用 [Workflow.Validate]
 func Load(serial []byte) (*Workflow, error) {
+	doc, err := DecodeDoc(serial)
+	if err != nil {
+		return nil, yerrors.Situated("Load", err)
+	}
+	if err := Migrate(doc); err != nil {
+		return nil, yerrors.Situated("Load", err)
+	}
+	serial, err = json.Marshal(doc)
+	if err != nil {
+		return nil, yerrors.Situated("Load", err)
+	}
 	var graph Workflow
-	err := json.Unmarshal(serial, &graph)
+	err = json.Unmarshal(serial, &graph)
 	if err != nil {
 		return nil, yerrors.Situated("Load", err)
 	}
@@ -2,11 +2,13 @@ package workflow_test
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path"
 	"strings"
 	"testing"
 
+	"github.com/super-yaoj/yaoj-core/pkg/processor"
 	"github.com/super-yaoj/yaoj-core/pkg/workflow"
 )
 
@@ -71,3 +73,382 @@ func TestWorkflow(t *testing.T) {
 	t.Log(err, (&workflow.Result{}).Byte())
 
 }
+
+func TestValidate(t *testing.T) {
+	work := workflow.New()
+	work.Node["compile"] = workflow.Node{ProcName: "compiler:auto"}
+	work.Node["run"] = workflow.Node{ProcName: "runner:auto"}
+	work.Node["check"] = workflow.Node{ProcName: "checker:unknown"}
+	work.Edge = []workflow.Edge{
+		{From: workflow.Outbound{Name: "compile", Label: "result"}, To: workflow.Inbound{Name: "run", Label: "executable"}},
+		{From: workflow.Outbound{Name: "run", Label: "stdout"}, To: workflow.Inbound{Name: "compile", Label: "source"}},
+		{From: workflow.Outbound{Name: "run", Label: "bad"}, To: workflow.Inbound{Name: "check", Label: "output"}},
+		{From: workflow.Outbound{Name: "run", Label: "stderr"}, To: workflow.Inbound{Name: "missing", Label: "input"}},
+	}
+	work.Inbound[workflow.Gsubm] = workflow.InboundFields{
+		"source": {{Name: "compile", Label: "source"}},
+	}
+	work.Inbound[workflow.Gtests] = workflow.InboundFields{
+		"input": {{Name: "run", Label: "stdin"}},
+	}
+	work.Inbound["bad.group"] = workflow.InboundFields{}
+	work.Inbound[workflow.Gsubtask] = workflow.InboundFields{}
+	work.Node["check"] = workflow.Node{ProcName: "checker:unknown", Conditions: []workflow.Condition{
+		{Node: "run", Code: "Accepted"},
+		{Node: "missing", Code: "Ok"},
+	}}
+
+	issues := work.Validate()
+	t.Log("\n", issues)
+	if !issues.HasError() {
+		t.Fatal("invalid workflow not reported")
+	}
+	for _, c := range []struct {
+		path     string
+		severity workflow.Severity
+		msg      string
+	}{
+		{"node.check", workflow.Error, "unknown processor"},
+		{"node.compile", workflow.Error, "multiple sources"},
+		{"node.compile", workflow.Error, `input "option" not connected`},
+		{"node.run", workflow.Error, `input "conf" not connected`},
+		{"node.compile", workflow.Info, `output "log" not used`},
+		{"edge", workflow.Error, "cycle"},
+		{"edge[2]", workflow.Error, "no output"},
+		{"edge[3]", workflow.Error, "unknown node"},
+		{"inbound.bad.group", workflow.Error, "invalid groupname"},
+		{"node.check.conditions[0]", workflow.Error, "unknown code"},
+		{"node.check.conditions[1]", workflow.Error, "unknown node"},
+	} {
+		found := false
+		for _, issue := range issues {
+			if issue.Path == c.path && issue.Severity == c.severity && strings.Contains(issue.Msg, c.msg) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatal("issue missing", c.path, c.msg)
+		}
+	}
+	for _, issue := range issues {
+		if issue.Path == "inbound.subtask" {
+			t.Fatal("custom group reported", issue)
+		}
+	}
+
+	// 由 Builder 构建的合法 workflow 没有 Error
+	var builder workflow.Builder
+	builder.SetNode("compile", "compiler:auto", false, true)
+	builder.SetNode("run", "runner:auto", true, false)
+	builder.AddInbound(workflow.Gsubm, "source", "compile", "source")
+	builder.AddInbound(workflow.Gsubm, "option", "compile", "option")
+	builder.AddInbound(workflow.Gstatic, "runconf", "run", "conf")
+	builder.AddInbound(workflow.Gtests, "input", "run", "stdin")
+	builder.AddEdge("compile", "result", "run", "executable")
+	valid, err := builder.Workflow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := valid.Validate(); issues.HasError() {
+		t.Fatal("valid workflow reported\n", issues)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	var builder workflow.Builder
+	builder.SetNode("compile", "compiler:auto", false, true)
+	builder.SetNode("run", "runner:auto", true, false)
+	builder.AddInbound(workflow.Gsubm, "source", "compile", "source")
+	builder.AddInbound(workflow.Gsubm, "option", "compile", "option")
+	builder.AddInbound(workflow.Gstatic, "runconf", "run", "conf")
+	builder.AddInbound(workflow.Gtests, "input", "run", "stdin")
+	builder.AddEdge("compile", "result", "run", "executable")
+	work, err := builder.Workflow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := work.DOT()
+	t.Log("\n", dot)
+	for _, s := range []string{
+		`n0 [shape=box, label="compile\ncompiler:auto\n(cache)"];`,
+		`n1 [shape=box, label="run\nrunner:auto"];`,
+		`[shape=ellipse, style=dashed, label="submission.source"];`,
+		`n0 -> n1 [label="result → executable"];`,
+	} {
+		if !strings.Contains(dot, s) {
+			t.Fatal("missing", s)
+		}
+	}
+	mermaid := work.Mermaid()
+	t.Log("\n", mermaid)
+	for _, s := range []string{
+		`n0["compile<br/>compiler:auto<br/>(cache)"]`,
+		`("tests.input")`,
+		`n0 -->|"result → executable"| n1`,
+	} {
+		if !strings.Contains(mermaid, s) {
+			t.Fatal("missing", s)
+		}
+	}
+	// 输出是确定的
+	for i := 0; i < 10; i++ {
+		if work.DOT() != dot || work.Mermaid() != mermaid {
+			t.Fatal("output not deterministic")
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	text := `# traditional
+compile = compiler:auto[flags = "-O2 -Wall", std = "c++17"](source <- submission.source, option <- submission.option) cache
+run = runner:auto(executable <- static.exe, stdin <- tests.input, conf <- static.runconf) if compile == Ok, compile != "TimeExceed"
+check = checker:testlib(checker <- static.chk, input <- tests.input, answer <- tests.answer)
+"odd name" = "proc with space"
+
+run.stdout -> check.output # comment
+compile.result -> "odd name"."odd label"
+`
+	work, err := workflow.ParseText([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.Node["compile"].Params["flags"] != "-O2 -Wall" || work.Node["compile"].Params["std"] != "c++17" {
+		t.Fatal("invalid params", work.Node["compile"])
+	}
+	if !work.Node["compile"].Cache || work.Node["run"].Cache {
+		t.Fatal("invalid cache flag", work.Node)
+	}
+	if len(work.Inbound[workflow.Gtests]["input"]) != 2 || len(work.Edge) != 2 {
+		t.Fatal("invalid workflow", work)
+	}
+	if len(work.Node["run"].Conditions) != 2 || !work.Node["run"].Conditions[1].Not {
+		t.Fatal("invalid conditions", work.Node["run"])
+	}
+	if work.Edge[1].To != (workflow.Inbound{Name: "odd name", Label: "odd label"}) {
+		t.Fatal("invalid edge", work.Edge[1])
+	}
+
+	// 往返
+	printed := work.Text()
+	t.Log("\n", printed)
+	work2, err := workflow.ParseText([]byte(printed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work2.Text() != printed {
+		t.Fatal("round trip failed\n", work2.Text())
+	}
+
+	for _, c := range []struct {
+		text string
+		line int
+		err  error
+	}{
+		{"a = b(\n", 1, workflow.ErrInvalidSyntax},
+		{"a = b\n\na.x -> b\n", 3, workflow.ErrInvalidSyntax},
+		{"a = b\na = c\n", 2, workflow.ErrDuplicateNode},
+		{"a = b nocache\n", 1, workflow.ErrInvalidSyntax},
+		{"a = b cache cache\n", 1, workflow.ErrInvalidSyntax},
+		{"a = b[x]\n", 1, workflow.ErrInvalidSyntax},
+		{"a = b if c = Ok\n", 1, workflow.ErrInvalidSyntax},
+		{"a = b if c == Ok,\n", 1, workflow.ErrInvalidSyntax},
+		{"a = b(x <- tests.y) $\n", 1, workflow.ErrInvalidSyntax},
+		{"a = b(x <- \"tests.y)\n", 1, workflow.ErrInvalidSyntax},
+	} {
+		_, err := workflow.ParseText([]byte(c.text))
+		var perr *workflow.ParseError
+		if !errors.As(err, &perr) || perr.Line != c.line || !errors.Is(err, c.err) {
+			t.Fatal("invalid error", c.text, err)
+		}
+	}
+}
+
+func TestComposite(t *testing.T) {
+	var inner workflow.Builder
+	inner.SetNode("compile", "compiler:testlib", false, true)
+	inner.SetNode("check", "checker:testlib", false, false)
+	inner.AddInbound(workflow.Gstatic, "checker", "compile", "source")
+	inner.AddInbound(workflow.Gtests, "input", "check", "input")
+	inner.AddInbound(workflow.Gtests, "output", "check", "output")
+	inner.AddInbound(workflow.Gtests, "answer", "check", "answer")
+	inner.AddEdge("compile", "result", "check", "checker")
+	checker, err := inner.Workflow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var builder workflow.Builder
+	builder.SetNode("compile", "compiler:auto", false, true)
+	builder.SetNode("run", "runner:auto", false, false)
+	builder.SetComposite("checker", checker, workflow.Outbounds{
+		"report": {Name: "check", Label: "xmlreport"},
+	})
+	builder.AddCondition("checker", workflow.Condition{Node: "run", Code: "Ok"})
+	builder.AddInbound(workflow.Gsubm, "source", "compile", "source")
+	builder.AddInbound(workflow.Gsubm, "option", "compile", "option")
+	builder.AddInbound(workflow.Gtests, "input", "run", "stdin")
+	builder.AddInbound(workflow.Gstatic, "runconf", "run", "conf")
+	builder.AddInbound(workflow.Gstatic, "chk", "checker", "checker")
+	builder.AddInbound(workflow.Gtests, "input", "checker", "input")
+	builder.AddInbound(workflow.Gtests, "answer", "checker", "answer")
+	builder.AddEdge("compile", "result", "run", "executable")
+	builder.AddEdge("run", "stdout", "checker", "output")
+	work, err := builder.Workflow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := work.Validate(); issues.HasError() {
+		t.Fatal("valid workflow reported\n", issues)
+	}
+
+	expanded, err := work.Expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("\n", expanded.Text())
+	check := expanded.Node["checker/check"]
+	if check.ProcName != "checker:testlib" || len(check.Conditions) != 1 || check.Conditions[0].Node != "run" {
+		t.Fatal("invalid expanded node", check)
+	}
+	found := false
+	for _, edge := range expanded.Edge {
+		if edge.From.Name == "run" && edge.To == (workflow.Inbound{Name: "checker/check", Label: "output"}) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("edge into composite node not expanded")
+	}
+	if bounds := expanded.Inbound[workflow.Gstatic]["chk"]; len(bounds) != 1 || bounds[0].Name != "checker/compile" {
+		t.Fatal("invalid expanded inbound", bounds)
+	}
+	if bound, err := work.Resolve(workflow.Outbound{Name: "checker", Label: "report"}); err != nil ||
+		bound != (workflow.Outbound{Name: "checker/check", Label: "xmlreport"}) {
+		t.Fatal("invalid resolved outbound", bound, err)
+	}
+
+	// 文本格式
+	printed := work.Text()
+	t.Log("\n", printed)
+	work2, err := workflow.ParseText([]byte(printed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work2.Text() != printed {
+		t.Fatal("round trip failed\n", work2.Text())
+	}
+	if _, err := workflow.ParseText([]byte("a = {\nb = c\n")); !errors.Is(err, workflow.ErrInvalidSyntax) {
+		t.Fatal("invalid error", err)
+	}
+	if !strings.Contains(work.DOT(), "(workflow)") {
+		t.Fatal("composite node not rendered")
+	}
+
+	// 非法的复合结点
+	work.Node["checker"].Composite.Outbound["bad"] = workflow.Outbound{Name: "check", Label: "unknown"}
+	work.Edge = append(work.Edge, workflow.Edge{
+		From: workflow.Outbound{Name: "compile", Label: "log"},
+		To:   workflow.Inbound{Name: "checker", Label: "unknown"},
+	})
+	// tests.input 与 static.input 会合并为同一个输入 label
+	work.Node["checker"].Composite.Workflow.Inbound[workflow.Gstatic]["input"] = []workflow.Inbound{
+		{Name: "compile", Label: "source"},
+	}
+	issues := work.Validate()
+	t.Log("\n", issues)
+	if !issues.HasError() || len(issues) != 3 {
+		t.Fatal("invalid issues", issues)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	// 没有版本号的旧文档
+	old := `{"node":{"run":{"processor":"runner:auto","key":true,"cache":false}},"edge":[],"inbound":{}}`
+	wk, err := workflow.Load([]byte(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wk.Version != workflow.Version || wk.Node["run"].ProcName != "runner:auto" {
+		t.Fatal("invalid migrated workflow", wk)
+	}
+	if workflow.New().Version != workflow.Version {
+		t.Fatal("invalid version of new workflow")
+	}
+
+	for _, doc := range []string{
+		`{"version":99,"node":{}}`,
+		`{"version":"1","node":{}}`,
+		`{"version":1,"node":{"sub":{"composite":{"workflow":{"version":99}}}}}`,
+	} {
+		if _, err := workflow.Load([]byte(doc)); !errors.Is(err, workflow.ErrUnsupportedVersion) {
+			t.Fatal("invalid error", doc, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	build := func(flags string, checker workflow.Groupname) *workflow.Workflow {
+		var builder workflow.Builder
+		builder.SetNode("compile", "compiler:auto", false, true, processor.Params{"flags": flags})
+		builder.SetNode("run", "runner:auto", false, false)
+		builder.SetNode("check", "checker:testlib", false, false)
+		builder.SetNode("checker_compile", "compiler:testlib", false, true)
+		builder.AddEdge("compile", "result", "run", "executable")
+		builder.AddEdge("run", "stdout", "check", "output")
+		builder.AddEdge("checker_compile", "result", "check", "checker")
+		builder.AddInbound(workflow.Gsubm, "source", "compile", "source")
+		builder.AddInbound(workflow.Gsubm, "option", "compile", "option")
+		builder.AddInbound(workflow.Gstatic, "runner_config", "run", "conf")
+		builder.AddInbound(workflow.Gtests, "input", "run", "stdin")
+		builder.AddInbound(workflow.Gtests, "input", "check", "input")
+		builder.AddInbound(workflow.Gtests, "output", "check", "answer")
+		builder.AddInbound(checker, "checker", "checker_compile", "source")
+		res, err := builder.Workflow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	a := build("-O2", workflow.Gstatic)
+	if diff := workflow.Diff(a, build("-O2", workflow.Gstatic)); !diff.Empty() || len(diff.Invalidated) > 0 {
+		t.Fatal("identical workflows differ\n", diff)
+	}
+
+	// 编译参数变化时，编译、运行与校验的缓存失效，但 checker 的编译不受影响
+	diff := workflow.Diff(a, build("-O0", workflow.Gstatic))
+	t.Log("\n", diff)
+	if len(diff.ChangedNodes) != 1 || diff.ChangedNodes[0].Name != "compile" || diff.ChangedNodes[0].Fields[0] != "params" {
+		t.Fatal("invalid changed nodes", diff.ChangedNodes)
+	}
+	if strings.Join(diff.Invalidated, ",") != "check,compile,run" {
+		t.Fatal("invalid invalidated nodes", diff.Invalidated)
+	}
+
+	// 读入数据的映射变化
+	diff = workflow.Diff(a, build("-O2", workflow.Gsubtask))
+	t.Log("\n", diff)
+	if len(diff.AddedInbounds) != 1 || len(diff.RemovedInbounds) != 1 ||
+		diff.AddedInbounds[0].String() != "subtask.checker -> checker_compile.source" {
+		t.Fatal("invalid inbound changes", diff)
+	}
+	if strings.Join(diff.Invalidated, ",") != "check,checker_compile" {
+		t.Fatal("invalid invalidated nodes", diff.Invalidated)
+	}
+
+	// 结点与边的增删，只修改 cache 不会使缓存失效
+	b := build("-O2", workflow.Gstatic)
+	delete(b.Node, "check")
+	b.Edge = b.Edge[:1]
+	b.Node["compile"] = workflow.Node{ProcName: "compiler:auto", Params: b.Node["compile"].Params}
+	b.Node["extra"] = workflow.Node{ProcName: "runner:auto"}
+	diff = workflow.Diff(a, b)
+	t.Log("\n", diff)
+	if strings.Join(diff.AddedNodes, ",") != "extra" || strings.Join(diff.RemovedNodes, ",") != "check" ||
+		len(diff.RemovedEdges) != 2 || len(diff.AddedEdges) != 0 {
+		t.Fatal("invalid node or edge changes", diff)
+	}
+	if diff.ChangedNodes[0].Fields[0] != "cache" || strings.Join(diff.Invalidated, ",") != "extra" {
+		t.Fatal("invalid invalidated nodes", diff)
+	}
+}
@@ -3,4 +3,3 @@ package yaojcore
 
 //go:generate go generate ./pkg/... -v
 //go:generate go generate ./internal/... -v
-//go:generate go run ./scripts/genprocs -path ./pkg/processor/builtins.go